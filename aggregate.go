@@ -0,0 +1,124 @@
+package tap13
+
+import "fmt"
+
+// Aggregate accumulates the results of many test runs (such as one for each test file in a suite),
+// identified by name. The counts are updated each time results are added.
+type Aggregate struct {
+	Files          int
+	PassingFiles   int
+	FailingFiles   int
+	BailedOutFiles int
+	TotalTests     int
+	PassedTests    int
+	FailedTests    int
+	SkippedTests   int
+	TodoTests      int
+	MissingTests   int
+
+	UnexpectedlyPassingTodos int
+
+	names   []string
+	results map[string]*Results
+	failing map[string]bool
+}
+
+// Add adds the results of a test run with the specified name. If results with the same name were
+// already added, they are replaced.
+func (a *Aggregate) Add(name string, results *Results) {
+	if a.results == nil {
+		a.results = map[string]*Results{}
+	}
+	if _, ok := a.results[name]; !ok {
+		a.names = append(a.names, name)
+	}
+	a.results[name] = results
+	a.update()
+}
+
+// update recalculates the counts from the results of each test run.
+func (a *Aggregate) update() {
+	*a = Aggregate{names: a.names, results: a.results, failing: a.failing}
+	for _, name := range a.names {
+		r := a.results[name]
+		a.Files++
+		if r.IsPassing() && !a.failing[name] {
+			a.PassingFiles++
+		} else {
+			a.FailingFiles++
+		}
+		if r.BailOut {
+			a.BailedOutFiles++
+		}
+		a.TotalTests += r.TotalTests
+		a.PassedTests += r.PassedTests
+		a.FailedTests += r.FailedTests
+		a.SkippedTests += r.SkippedTests
+		a.TodoTests += r.TodoTests
+		a.UnexpectedlyPassingTodos += r.UnexpectedlyPassingTodos
+		if r.ExpectedTests > r.TotalTests {
+			a.MissingTests += r.ExpectedTests - r.TotalTests
+		}
+	}
+}
+
+// MarkFailing records that the test run with the specified name failed for a reason which is not
+// reflected in its results, such as the test program exiting with a nonzero exit status. The test
+// run is then counted as failing, even if its results are passing.
+func (a *Aggregate) MarkFailing(name string) {
+	if a.failing == nil {
+		a.failing = map[string]bool{}
+	}
+	a.failing[name] = true
+	a.update()
+}
+
+// Names returns the names of the test runs, in the order they were added.
+func (a *Aggregate) Names() []string {
+	return append([]string(nil), a.names...)
+}
+
+// Results returns the results of the test run with the specified name, or nil if there is no such
+// test run.
+func (a *Aggregate) Results(name string) *Results {
+	return a.results[name]
+}
+
+// IsPassing checks if the results of every test run are passing. An Aggregate without any test
+// runs is not considered passing.
+func (a *Aggregate) IsPassing() bool {
+	return a.Files > 0 && a.FailingFiles == 0
+}
+
+func (a *Aggregate) String() string {
+	var result = ""
+	if a.IsPassing() {
+		result += " Overall result: PASS\n"
+	} else {
+		result += " Overall result: FAIL\n"
+	}
+	result += fmt.Sprintf("  Passing files: %d/%d\n", a.PassingFiles, a.Files)
+	if a.BailedOutFiles > 0 {
+		result += fmt.Sprintf("     Bailed out: %d/%d\n", a.BailedOutFiles, a.Files)
+	}
+	result += fmt.Sprintf("Total tests run: %d\n", a.TotalTests)
+	if a.MissingTests > 0 {
+		result += fmt.Sprintf("  Missing tests: %d\n", a.MissingTests)
+	}
+	if a.PassedTests > 0 {
+		result += fmt.Sprintf("   Passed tests: %d\n", a.PassedTests)
+	}
+	if a.FailedTests > 0 {
+		result += fmt.Sprintf("   Failed tests: %d\n", a.FailedTests)
+	}
+	if a.SkippedTests > 0 {
+		result += fmt.Sprintf("  Skipped tests: %d\n", a.SkippedTests)
+	}
+	if a.TodoTests > 0 {
+		result += fmt.Sprintf("     TODO tests: %d\n", a.TodoTests)
+	}
+	if a.UnexpectedlyPassingTodos > 0 {
+		result += fmt.Sprintf("    TODO passed: %d\n", a.UnexpectedlyPassingTodos)
+	}
+	return result
+}
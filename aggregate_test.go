@@ -0,0 +1,81 @@
+package tap13
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregate(t *testing.T) {
+	t.Run("EmptyAggregateFails", func(t *testing.T) {
+		aggregate := &Aggregate{}
+		assert.False(t, aggregate.IsPassing())
+		assert.Nil(t, aggregate.Results("missing"))
+		assert.Equal(t, ` Overall result: FAIL
+  Passing files: 0/0
+Total tests run: 0
+`, aggregate.String())
+	})
+	t.Run("PassingResultsPass", func(t *testing.T) {
+		aggregate := &Aggregate{}
+		aggregate.Add("a.t", Parse(strings.Split("TAP version 13\n1..2\nok\nok", "\n")))
+		aggregate.Add("b.t", Parse(strings.Split("TAP version 13\nok\nok # SKIP", "\n")))
+		assert.True(t, aggregate.IsPassing())
+		assert.Equal(t, []string{"a.t", "b.t"}, aggregate.Names())
+		assert.Equal(t, 2, aggregate.Results("b.t").TotalTests)
+		assert.Equal(t, ` Overall result: PASS
+  Passing files: 2/2
+Total tests run: 4
+   Passed tests: 3
+  Skipped tests: 1
+`, aggregate.String())
+	})
+	t.Run("CountsRunsMarkedFailing", func(t *testing.T) {
+		aggregate := &Aggregate{}
+		aggregate.Add("a.t", Parse([]string{"TAP version 13", "1..1", "ok 1"}))
+		aggregate.Add("b.t", Parse([]string{"TAP version 13", "1..1", "ok 1"}))
+		aggregate.MarkFailing("b.t")
+		assert.False(t, aggregate.IsPassing())
+		assert.Equal(t, 1, aggregate.PassingFiles)
+		assert.Equal(t, 1, aggregate.FailingFiles)
+		assert.Equal(t, 2, aggregate.PassedTests)
+	})
+	t.Run("CountsPassingTodoTests", func(t *testing.T) {
+		aggregate := &Aggregate{}
+		aggregate.Add("a.t", Parse(strings.Split("TAP version 13\nok # TODO\nnot ok # TODO", "\n")))
+		assert.True(t, aggregate.IsPassing())
+		assert.Equal(t, 1, aggregate.UnexpectedlyPassingTodos)
+		assert.Contains(t, aggregate.String(), "    TODO passed: 1\n")
+	})
+	t.Run("AccumulatesTotals", func(t *testing.T) {
+		aggregate := &Aggregate{}
+		aggregate.Add("a.t", Parse(strings.Split("TAP version 13\n1..3\nok\nnot ok", "\n")))
+		aggregate.Add("b.t", Parse(strings.Split("TAP version 13\nok\nBail out!", "\n")))
+		aggregate.Add("c.t", Parse(strings.Split("TAP version 13\nnot ok # TODO", "\n")))
+		assert.False(t, aggregate.IsPassing())
+		assert.Equal(t, 3, aggregate.Files)
+		assert.Equal(t, 1, aggregate.PassingFiles)
+		assert.Equal(t, 2, aggregate.FailingFiles)
+		assert.Equal(t, 1, aggregate.BailedOutFiles)
+		assert.Equal(t, 1, aggregate.MissingTests)
+		assert.Equal(t, ` Overall result: FAIL
+  Passing files: 1/3
+     Bailed out: 1/3
+Total tests run: 4
+  Missing tests: 1
+   Passed tests: 2
+   Failed tests: 1
+     TODO tests: 1
+`, aggregate.String())
+	})
+	t.Run("AddingSameNameReplacesResults", func(t *testing.T) {
+		aggregate := &Aggregate{}
+		aggregate.Add("a.t", Parse(strings.Split("TAP version 13\nnot ok", "\n")))
+		aggregate.Add("a.t", Parse(strings.Split("TAP version 13\nok", "\n")))
+		assert.True(t, aggregate.IsPassing())
+		assert.Equal(t, 1, aggregate.Files)
+		assert.Equal(t, 1, aggregate.TotalTests)
+		assert.Equal(t, []string{"a.t"}, aggregate.Names())
+	})
+}
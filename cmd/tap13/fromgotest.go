@@ -0,0 +1,45 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mpontillo/tap13/gotest"
+)
+
+// fromGoTest implements the "from-gotest" subcommand, which converts the output of "go test -json"
+// to TAP.
+func fromGoTest(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("tap13 from-gotest", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: tap13 from-gotest [flags] [file]")
+		flags.PrintDefaults()
+	}
+	options := gotest.Options{}
+	flags.BoolVar(&options.Subtests, "subtests", false, "write each package as a subtest")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return exitUsage
+	}
+	input := stdin
+	if name := flags.Arg(0); name != "" && name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			fmt.Fprintf(stderr, "Could not read %s: %s\n", name, err)
+			return exitReadError
+		}
+		defer file.Close()
+		input = file
+	}
+	if err := gotest.ToTAP(input, stdout, options); err != nil {
+		fmt.Fprintf(stderr, "Could not convert input: %s\n", err)
+		return exitReadError
+	}
+	return exitPass
+}
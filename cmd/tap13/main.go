@@ -1,19 +1,338 @@
+/*
+Command tap13 reads the TAP output in each file specified as an argument, and prints a summary of
+the test results. If no files are specified, or a file is named "-", the standard input is read.
+This allows the output of a test program to be piped into the command:
+
+	./run_tests | tap13
+
+Usage:
+
+	tap13 [flags] [file...]
+	tap13 run [flags] path...
+	tap13 from-gotest [--subtests] [file]
+	tap13 validate [--quiet] [file...]
+
+The flags are:
+
+	--format text|junit|gotest|json
+		The output format. The default (text) prints a summary of each file, followed by a
+		combined summary of all files. The junit format writes a JUnit XML document. The
+		gotest format writes the event stream that "go test -json" would produce, treating
+		each file as a package. The json format writes a JSON object for each file, on a
+		line of its own, with the name of the file under the "file" key and its results
+		(as encoded by Results.MarshalJSON) under the "results" key.
+	--lines
+		In the json format, also include the lines of TAP output of each file.
+	--quiet
+		Do not print anything; only set the exit status.
+	--verbose
+		In the text format, also print each failing test with its diagnostics.
+	--tee
+		Copy the TAP output to the standard output as it is read, before printing the
+		results. This is done even if --quiet is specified. For any format other than
+		text, the TAP output is copied to the standard error instead.
+	--fail-on-todo-pass
+		Treat any TODO test which passes as a failure, so that its TODO directive is
+		removed.
+
+The run subcommand runs each test program specified as an argument (or each file in a directory
+specified as an argument), parses its standard output as TAP, and prints a summary of the results
+of each test program, followed by a combined summary. The result of each test program is printed
+in the order given, as soon as it is available. If the standard error is a terminal, a progress
+line is displayed for each running test program. The flags are:
+
+	-j N
+		Run up to N test programs concurrently.
+	--timeout D
+		Stop any test program that runs for longer than the duration D (such as "30s").
+	--fail-fast
+		Stop all test programs (and do not start any more) as soon as one bails out.
+	--interpreter ext=command
+		Run files with the extension ext using the specified command (such as
+		".py=python3"). May be repeated. Otherwise, the interpreter on the "#!" line of
+		the file is used, if any; otherwise the file is executed directly.
+	--quiet
+		Do not print anything; only set the exit status.
+	--verbose
+		Also print each failing test with its diagnostics, and the standard error output
+		of each test program.
+	--fail-on-todo-pass
+		Treat any TODO test which passes as a failure.
+
+A test program fails if it times out or exits with a nonzero exit status, even if its TAP output
+indicates that all of its tests passed.
+
+The from-gotest subcommand reads the output of "go test -json" from the specified file (or the
+standard input) and writes the corresponding TAP to the standard output:
+
+	go test -json ./... | tap13 from-gotest | tap13
+
+With --subtests, each package is written as a subtest; otherwise, each Go test is written as a
+test in a single TAP stream.
+
+The validate subcommand parses each file (or the standard input) strictly, and prints each
+violation of the TAP specification it finds, such as a missing or misplaced plan, more than one
+plan, test numbers which are duplicated or out of sequence, tests beyond the plan, or an
+unterminated YAML block. Any violation causes the file to fail.
+
+The exit status reflects the worst result found in any of the files:
+
+	0	All tests passed.
+	1	At least one test failed, a planned test was not run, or (for validate) the TAP
+		output violated the specification.
+	2	The command was used incorrectly.
+	3	A test run bailed out.
+	4	No TAP output was found.
+	5	A file could not be read, or a test program could not be run.
+*/
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/mpontillo/tap13"
-	util "github.com/mpontillo/tap13/internal"
+	"github.com/mpontillo/tap13/gotest"
+	"github.com/mpontillo/tap13/junit"
+)
+
+// Exit codes, in increasing order of severity.
+const (
+	exitPass = iota
+	exitTestFailures
+	exitUsage
+	exitBailOut
+	exitNoTap
+	exitReadError
 )
 
+// stdinName is the name used for the standard input in the output.
+const stdinName = "<stdin>"
+
 func main() {
-	args := os.Args[1:]
-	for _, arg := range args {
-		fmt.Println(arg)
-		contents := util.ReadFile(arg)
-		results := tap13.Parse(contents)
-		fmt.Println(results)
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) > 0 {
+		switch args[0] {
+		case "run":
+			return runHarness(args[1:], stdout, stderr)
+		case "from-gotest":
+			return fromGoTest(args[1:], stdin, stdout, stderr)
+		case "validate":
+			return validate(args[1:], stdin, stdout, stderr)
+		}
+	}
+	flags := flag.NewFlagSet("tap13", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "text", "output format: text, junit, gotest or json")
+	quiet := flags.Bool("quiet", false, "do not print anything; only set the exit status")
+	verbose := flags.Bool("verbose", false, "print each failing test with its diagnostics")
+	tee := flags.Bool("tee", false, "copy the TAP output to the standard output (or error) as it is read")
+	failOnTodoPass := flags.Bool("fail-on-todo-pass", false, "fail if any TODO test passes")
+	lines := flags.Bool("lines", false, "include the TAP output lines in the json format")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *format != "text" && *format != "junit" && *format != "gotest" && *format != "json" {
+		fmt.Fprintf(stderr, "Unknown output format: %s\n", *format)
+		flags.Usage()
+		return exitUsage
+	}
+	var teeOutput io.Writer
+	if *tee {
+		teeOutput = stdout
+		if *format != "text" {
+			// Keep the machine-readable output intact.
+			teeOutput = stderr
+		}
+	}
+	if *quiet {
+		stdout = ioutil.Discard
+	}
+	names := flags.Args()
+	if len(names) == 0 {
+		names = []string{"-"}
 	}
+	options := tap13.ParseOptions{FailPassingTodos: *failOnTodoPass}
+	status := exitPass
+	var suites []junit.Suite
+	for _, name := range names {
+		results, err := readResults(name, stdin, teeOutput, options)
+		if err != nil {
+			fmt.Fprintf(stderr, "Could not read %s: %s\n", name, err)
+			status = exitReadError
+			continue
+		}
+		if name == "-" {
+			name = stdinName
+		}
+		suites = append(suites, junit.Suite{Name: name, Results: results})
+	}
+	switch *format {
+	case "junit":
+		if err := junit.Write(stdout, suites...); err != nil {
+			fmt.Fprintf(stderr, "Could not write output: %s\n", err)
+		}
+	case "gotest":
+		for _, suite := range suites {
+			if err := gotest.WriteEvents(stdout, suite.Name, suite.Results); err != nil {
+				fmt.Fprintf(stderr, "Could not write output: %s\n", err)
+				break
+			}
+		}
+	case "json":
+		encoder := json.NewEncoder(stdout)
+		encoder.SetEscapeHTML(false)
+		for _, suite := range suites {
+			results := suite.Results
+			if !*lines {
+				withoutLines := *results
+				withoutLines.Lines = nil
+				results = &withoutLines
+			}
+			encoded := &jsonSuite{File: suite.Name, Results: results}
+			if err := encoder.Encode(encoded); err != nil {
+				fmt.Fprintf(stderr, "Could not write output: %s\n", err)
+				break
+			}
+		}
+	default:
+		for _, suite := range suites {
+			fmt.Fprintln(stdout, suite.Name)
+			fmt.Fprintln(stdout, suite.Results)
+			if *verbose {
+				printFailures(stdout, suite.Results)
+			}
+		}
+		if len(suites) > 1 {
+			aggregate := &tap13.Aggregate{}
+			for _, suite := range suites {
+				aggregate.Add(suite.Name, suite.Results)
+			}
+			printTotals(stdout, aggregate)
+		}
+	}
+	for _, suite := range suites {
+		if code := exitCode(suite.Results); code > status {
+			status = code
+		}
+	}
+	return status
+}
+
+// jsonSuite is the JSON object written for each file by the json format.
+type jsonSuite struct {
+	File    string         `json:"file"`
+	Results *tap13.Results `json:"results"`
+}
+
+// readResults parses the TAP output in the specified file (or the standard input, if the name is
+// "-"), according to the specified options. If tee is not nil, the TAP output is copied to it as it
+// is read.
+func readResults(
+	name string, stdin io.Reader, tee io.Writer, options tap13.ParseOptions,
+) (*tap13.Results, error) {
+	input := stdin
+	if name != "-" {
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		input = file
+	}
+	if tee != nil {
+		input = io.TeeReader(input, tee)
+	}
+	parser := tap13.NewParserWithOptions(options, nil)
+	_, err := parser.ReadFrom(input)
+	return parser.Finish(), err
+}
+
+// exitCode returns the exit code corresponding to the specified results.
+func exitCode(results *tap13.Results) int {
+	switch {
+	case !results.FoundTapData:
+		return exitNoTap
+	case results.BailOut:
+		return exitBailOut
+	case !results.IsPassing():
+		return exitTestFailures
+	}
+	return exitPass
+}
+
+// printFailures prints each failing test, followed by its diagnostics and YAML block. Failing tests
+// beyond the plan are labelled "extra:". If passing TODO tests were treated as failures, each of
+// them is printed too.
+func printFailures(w io.Writer, results *tap13.Results) {
+	failures := 0
+	for _, test := range results.Tests {
+		if !test.Failed {
+			continue
+		}
+		line := "not ok"
+		if test.Extra {
+			// Tests beyond the plan don't fail the run, but are labelled so they can be found.
+			line = "extra: not ok"
+		}
+		if test.TestNumber > 0 {
+			line += fmt.Sprintf(" %d", test.TestNumber)
+		}
+		if test.Description != "" {
+			line += " " + test.Description
+		}
+		fmt.Fprintln(w, line)
+		failures++
+		for _, diagnostic := range test.Diagnostics {
+			fmt.Fprintf(w, "    # %s\n", diagnostic)
+		}
+		if len(test.YamlBytes) > 0 {
+			yaml := strings.TrimSuffix(string(test.YamlBytes), "\n")
+			for _, yamlLine := range strings.Split(yaml, "\n") {
+				fmt.Fprintf(w, "    %s\n", yamlLine)
+			}
+		}
+	}
+	if hasProblem(results, tap13.PassingTodo) {
+		for _, test := range results.UnexpectedlyPassingTodoTests() {
+			fmt.Fprintf(w, "TODO passed: %s\n", test.Raw)
+			failures++
+		}
+	}
+	if len(results.MissingTestNumbers) > 0 {
+		var numbers []string
+		for _, number := range results.MissingTestNumbers {
+			numbers = append(numbers, strconv.Itoa(number))
+		}
+		fmt.Fprintf(w, "missing tests: %s\n", strings.Join(numbers, ", "))
+		failures++
+	}
+	if failures > 0 {
+		fmt.Fprintln(w)
+	}
+}
+
+// hasProblem checks if a problem with the specified code was found in the results.
+func hasProblem(results *tap13.Results, code tap13.ProblemCode) bool {
+	for _, problem := range results.Problems {
+		if problem.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// printTotals prints a combined summary of the results of all files.
+func printTotals(w io.Writer, aggregate *tap13.Aggregate) {
+	fmt.Fprintln(w, "Total")
+	fmt.Fprint(w, aggregate)
 }
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	passingTap = "TAP version 13\n1..2\nok 1 - first\nok 2 - second\n"
+	failingTap = "TAP version 13\n1..2\nok 1 - first\nnot ok 2 - second\n"
+	todoTap    = "TAP version 13\n1..2\nok 1 - first\nok 2 - second # TODO later\n"
+)
+
+// runCommand runs the command with the specified arguments and standard input, and returns its
+// exit status, standard output and standard error.
+func runCommand(args []string, stdin string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	status := run(args, strings.NewReader(stdin), &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestRun(t *testing.T) {
+	t.Run("ReturnsDocumentedExitCodes", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			args   []string
+			stdin  string
+			status int
+		}{
+			{"Passing", nil, passingTap, exitPass},
+			{"Failing", nil, failingTap, exitTestFailures},
+			{"MissingTests", nil, "TAP version 13\n1..3\nok 1\n", exitTestFailures},
+			{"BailOut", []string{"../../testdata/bail_out.tap13"}, "", exitBailOut},
+			{"NoTap", nil, "no TAP here\n", exitNoTap},
+			{"ReadError", []string{"../../testdata/does_not_exist.tap13"}, "", exitReadError},
+			{"UnknownFlag", []string{"--bogus"}, passingTap, exitUsage},
+			{"UnknownFormat", []string{"--format", "yaml"}, passingTap, exitUsage},
+			{"WorstOfManyFiles", []string{"-", "../../testdata/bail_out.tap13"}, failingTap,
+				exitBailOut},
+			{"TodoPassing", nil, todoTap, exitPass},
+			{"FailOnTodoPass", []string{"--fail-on-todo-pass"}, todoTap, exitTestFailures},
+		}
+		for _, test := range tests {
+			status, _, _ := runCommand(test.args, test.stdin)
+			assert.Equal(t, test.status, status, test.name)
+		}
+	})
+	t.Run("ReadsStandardInput", func(t *testing.T) {
+		for _, args := range [][]string{nil, {"-"}} {
+			status, stdout, _ := runCommand(args, failingTap)
+			assert.Equal(t, exitTestFailures, status)
+			assert.True(t, strings.HasPrefix(stdout, "<stdin>\n Overall result: FAIL\n"), stdout)
+		}
+	})
+	t.Run("CopiesInputWithTee", func(t *testing.T) {
+		status, stdout, stderr := runCommand([]string{"--tee"}, passingTap)
+		assert.Equal(t, exitPass, status)
+		assert.True(t, strings.HasPrefix(stdout, passingTap+"<stdin>\n"), stdout)
+		assert.Empty(t, stderr)
+		status, stdout, _ = runCommand([]string{"--tee", "--quiet"}, passingTap)
+		assert.Equal(t, exitPass, status)
+		assert.Equal(t, passingTap, stdout)
+		status, stdout, stderr = runCommand([]string{"--tee", "--format", "json"}, passingTap)
+		assert.Equal(t, exitPass, status)
+		assert.Equal(t, passingTap, stderr)
+		assert.True(t, json.Valid([]byte(stdout)), stdout)
+	})
+	t.Run("ValidatesStrictly", func(t *testing.T) {
+		tests := []struct {
+			name   string
+			stdin  string
+			status int
+			output string
+		}{
+			{"WellFormed", passingTap, exitPass, "<stdin>: ok\n"},
+			{"MissingPlan", "TAP version 13\nok 1\n", exitTestFailures,
+				"<stdin>: error: missing-plan\n"},
+			{"NoTap", "no TAP here\n", exitNoTap, ""},
+		}
+		for _, test := range tests {
+			status, stdout, _ := runCommand([]string{"validate"}, test.stdin)
+			assert.Equal(t, test.status, status, test.name)
+			assert.Equal(t, test.output, stdout, test.name)
+		}
+	})
+	t.Run("WritesJUnit", func(t *testing.T) {
+		status, stdout, _ := runCommand([]string{"--format", "junit"}, failingTap)
+		assert.Equal(t, exitTestFailures, status)
+		var suites struct {
+			Suites []struct {
+				Name     string `xml:"name,attr"`
+				Tests    int    `xml:"tests,attr"`
+				Failures int    `xml:"failures,attr"`
+			} `xml:"testsuite"`
+		}
+		assert.NoError(t, xml.Unmarshal([]byte(stdout), &suites))
+		assert.Len(t, suites.Suites, 1)
+		assert.Equal(t, "<stdin>", suites.Suites[0].Name)
+		assert.Equal(t, 2, suites.Suites[0].Tests)
+		assert.Equal(t, 1, suites.Suites[0].Failures)
+	})
+	t.Run("WritesJSON", func(t *testing.T) {
+		tests := []struct {
+			args  []string
+			lines bool
+		}{
+			{[]string{"--format", "json"}, false},
+			{[]string{"--format", "json", "--lines"}, true},
+		}
+		for _, test := range tests {
+			status, stdout, _ := runCommand(test.args, failingTap)
+			assert.Equal(t, exitTestFailures, status)
+			assert.Equal(t, 1, strings.Count(stdout, "\n"), "one JSON object per line")
+			var decoded map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(stdout), &decoded))
+			assert.Equal(t, "<stdin>", decoded["file"])
+			results := decoded["results"].(map[string]interface{})
+			assert.Equal(t, "fail", results["status"])
+			assert.Equal(t, test.lines, results["lines"] != nil, test.args)
+		}
+	})
+	t.Run("RunsTestPrograms", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "tap13")
+		assert.NoError(t, err)
+		defer os.RemoveAll(dir)
+		script := filepath.Join(dir, "exit.t")
+		content := "#!/bin/sh\necho 'TAP version 13'\necho '1..1'\necho 'ok 1'\nexit 2\n"
+		assert.NoError(t, ioutil.WriteFile(script, []byte(content), 0755))
+		status, stdout, _ := runCommand([]string{"run", script}, "")
+		assert.Equal(t, exitTestFailures, status)
+		assert.Contains(t, stdout, "FAIL (exit status 2)\n")
+		assert.Contains(t, stdout, " Overall result: FAIL\n  Passing files: 0/1\n")
+	})
+	t.Run("ReportsPassingTodoTestsAsFailures", func(t *testing.T) {
+		args := []string{"--fail-on-todo-pass", "--verbose"}
+		status, stdout, _ := runCommand(args, todoTap)
+		assert.Equal(t, exitTestFailures, status)
+		assert.Contains(t, stdout, " Overall result: FAIL\n")
+		assert.Contains(t, stdout, "TODO passed: ok 2 - second # TODO later\n")
+	})
+	t.Run("LabelsExtraTests", func(t *testing.T) {
+		stdin := "TAP version 13\n1..2\nok 1\nok 2\nnot ok 3 - surprise\n"
+		status, stdout, _ := runCommand([]string{"--verbose"}, stdin)
+		assert.Equal(t, exitPass, status)
+		assert.Contains(t, stdout, " Overall result: PASS\n    Extra tests: 1\n")
+		assert.Contains(t, stdout, "extra: not ok 3 - surprise\n")
+	})
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mpontillo/tap13"
+	"github.com/mpontillo/tap13/harness"
+)
+
+// redrawInterval limits how often the progress lines are redrawn as TAP output is parsed.
+const redrawInterval = 100 * time.Millisecond
+
+// reporter prints the result of each test program in the order the test programs were given, as
+// soon as the result (and the result of each test program before it) is available. If a terminal
+// is available, a live progress line is also displayed for each running test program.
+type reporter struct {
+	mutex    sync.Mutex
+	stdout   io.Writer
+	terminal io.Writer
+	paths    []string
+	width    int
+	verbose  bool
+	results  []*harness.Result
+	next     int
+	running  map[int]int
+	drawn    int
+	lastDraw time.Time
+}
+
+func newReporter(stdout io.Writer, terminal io.Writer, paths []string, verbose bool) *reporter {
+	r := &reporter{
+		stdout:   stdout,
+		terminal: terminal,
+		paths:    paths,
+		verbose:  verbose,
+		results:  make([]*harness.Result, len(paths)),
+		running:  map[int]int{},
+	}
+	for _, path := range paths {
+		if len(path) > r.width {
+			r.width = len(path)
+		}
+	}
+	return r
+}
+
+// isTerminal checks if the specified writer is a terminal.
+func isTerminal(w io.Writer) bool {
+	file, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// leader returns the path of the specified test program, padded with dots for alignment.
+func (r *reporter) leader(path string) string {
+	return path + " " + strings.Repeat(".", r.width-len(path)+2)
+}
+
+// update is called by the harness.Runner as each test program progresses.
+func (r *reporter) update(progress harness.Progress) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	switch {
+	case progress.Started:
+		r.running[progress.Index] = 0
+	case progress.Event != nil:
+		if progress.Event.Type != tap13.TestEvent || progress.Event.Depth > 0 {
+			return
+		}
+		r.running[progress.Index]++
+		if time.Since(r.lastDraw) < redrawInterval {
+			return
+		}
+	case progress.Result != nil:
+		delete(r.running, progress.Index)
+		r.results[progress.Index] = progress.Result
+		r.clear()
+		for r.next < len(r.results) && r.results[r.next] != nil {
+			r.print(r.results[r.next])
+			r.next++
+		}
+	}
+	r.draw()
+}
+
+// print prints the result of the specified test program.
+func (r *reporter) print(result *harness.Result) {
+	fmt.Fprintf(r.stdout, "%s %s\n", r.leader(result.Path), describe(result))
+	if r.verbose {
+		printFailures(r.stdout, result.Results)
+		if len(result.Stderr) > 0 {
+			fmt.Fprintf(r.stdout, "%s\n", strings.TrimSuffix(string(result.Stderr), "\n"))
+		}
+	}
+}
+
+// clear erases the progress lines from the terminal.
+func (r *reporter) clear() {
+	if r.terminal == nil || r.drawn == 0 {
+		return
+	}
+	// Move the cursor to the start of the first progress line, and erase to the end of the screen.
+	fmt.Fprintf(r.terminal, "\033[%dF\033[J", r.drawn)
+	r.drawn = 0
+}
+
+// draw displays a progress line for each running test program.
+func (r *reporter) draw() {
+	if r.terminal == nil {
+		return
+	}
+	r.clear()
+	var indexes []int
+	for index := range r.running {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		fmt.Fprintf(r.terminal, "%s running (%d tests)\n", r.leader(r.paths[index]),
+			r.running[index])
+	}
+	r.drawn = len(indexes)
+	r.lastDraw = time.Now()
+}
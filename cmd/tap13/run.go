@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/mpontillo/tap13"
+	"github.com/mpontillo/tap13/harness"
+)
+
+// interpreterFlag collects "ext=command" arguments into a map of interpreters.
+type interpreterFlag map[string][]string
+
+func (f interpreterFlag) String() string {
+	var values []string
+	for ext, command := range f {
+		values = append(values, ext+"="+strings.Join(command, " "))
+	}
+	return strings.Join(values, ",")
+}
+
+func (f interpreterFlag) Set(value string) error {
+	parts := strings.SplitN(value, "=", 2)
+	if len(parts) != 2 || parts[0] == "" || strings.TrimSpace(parts[1]) == "" {
+		return fmt.Errorf("expected ext=command, got %q", value)
+	}
+	ext := parts[0]
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	f[ext] = strings.Fields(parts[1])
+	return nil
+}
+
+// runHarness implements the "run" subcommand, which runs test programs and summarizes their
+// results.
+func runHarness(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("tap13 run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: tap13 run [flags] path...")
+		flags.PrintDefaults()
+	}
+	interpreters := interpreterFlag{}
+	runner := &harness.Runner{Interpreters: interpreters}
+	flags.IntVar(&runner.Jobs, "j", 1, "number of test programs to run concurrently")
+	flags.DurationVar(&runner.Timeout, "timeout", 0, "maximum duration of each test program")
+	flags.Var(interpreters, "interpreter", "run files with extension `ext=command` (repeatable)")
+	quiet := flags.Bool("quiet", false, "do not print anything; only set the exit status")
+	verbose := flags.Bool("verbose", false, "print failing tests and the standard error output")
+	flags.BoolVar(&runner.FailFast, "fail-fast", false, "stop all test programs if one bails out")
+	flags.BoolVar(&runner.Options.FailPassingTodos, "fail-on-todo-pass", false,
+		"fail if any TODO test passes")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+	if *quiet {
+		stdout = ioutil.Discard
+	}
+	paths, err := harness.Expand(flags.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "Could not find test programs: %s\n", err)
+		return exitReadError
+	}
+	var terminal io.Writer
+	if !*quiet && isTerminal(stderr) {
+		terminal = stderr
+	}
+	reporter := newReporter(stdout, terminal, paths, *verbose)
+	runner.Progress = reporter.update
+	results := runner.Run(context.Background(), paths)
+	status := exitPass
+	aggregate := &tap13.Aggregate{}
+	for _, result := range results {
+		aggregate.Add(result.Path, result.Results)
+		code := runExitCode(&result)
+		if code != exitPass {
+			// The test program may have failed even though its TAP output was passing.
+			aggregate.MarkFailing(result.Path)
+		}
+		if code > status {
+			status = code
+		}
+	}
+	printTotals(stdout, aggregate)
+	return status
+}
+
+// describe returns a short description of the outcome of the specified test program.
+func describe(result *harness.Result) string {
+	results := result.Results
+	switch {
+	case result.Err != nil:
+		return fmt.Sprintf("ERROR (%s)", result.Err)
+	case result.TimedOut:
+		return "FAIL (timed out)"
+	case result.Canceled:
+		return "FAIL (canceled)"
+	case !results.FoundTapData:
+		return "FAIL (no TAP output)"
+	case results.BailOut:
+		return "FAIL (bailed out)"
+	case !results.IsPassing():
+		planned := results.TotalTests
+		if results.ExpectedTests > planned {
+			planned = results.ExpectedTests
+		}
+		description := fmt.Sprintf("%d/%d tests passed",
+			results.PassedTests+results.SkippedTests+results.TodoTests, planned)
+		if hasProblem(results, tap13.PassingTodo) {
+			description += fmt.Sprintf(", %d TODO passed", results.UnexpectedlyPassingTodos)
+		}
+		return fmt.Sprintf("FAIL (%s)", description)
+	case result.ExitCode != 0:
+		return fmt.Sprintf("FAIL (exit status %d)", result.ExitCode)
+	case results.PlanSkipped && results.PlanSkipReason != "":
+		return fmt.Sprintf("skipped (%s)", results.PlanSkipReason)
+	case results.PlanSkipped:
+		return "skipped"
+	}
+	return "ok"
+}
+
+// runExitCode returns the exit code corresponding to the specified test program result.
+func runExitCode(result *harness.Result) int {
+	if result.Err != nil {
+		return exitReadError
+	}
+	if result.TimedOut || result.Canceled {
+		return exitTestFailures
+	}
+	if code := exitCode(result.Results); code != exitPass {
+		return code
+	}
+	if !result.IsPassing() {
+		return exitTestFailures
+	}
+	return exitPass
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/mpontillo/tap13"
+)
+
+// validate implements the "validate" subcommand, which reports each violation of the TAP
+// specification found in the specified files.
+func validate(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("tap13 validate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: tap13 validate [flags] [file...]")
+		flags.PrintDefaults()
+	}
+	quiet := flags.Bool("quiet", false, "do not print anything; only set the exit status")
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *quiet {
+		stdout = ioutil.Discard
+	}
+	names := flags.Args()
+	if len(names) == 0 {
+		names = []string{"-"}
+	}
+	status := exitPass
+	options := tap13.ParseOptions{Strict: true}
+	for _, name := range names {
+		results, err := readResults(name, stdin, nil, options)
+		if err != nil {
+			fmt.Fprintf(stderr, "Could not read %s: %s\n", name, err)
+			status = exitReadError
+			continue
+		}
+		if name == "-" {
+			name = stdinName
+		}
+		code := exitPass
+		if !results.FoundTapData {
+			code = exitNoTap
+		} else if printProblems(stdout, name, results) > 0 {
+			code = exitTestFailures
+		}
+		if code == exitPass {
+			fmt.Fprintf(stdout, "%s: ok\n", name)
+		}
+		if code > status {
+			status = code
+		}
+	}
+	return status
+}
+
+// printProblems prints each problem found in the specified results (including the results of any
+// subtests), and returns the number of problems printed.
+func printProblems(w io.Writer, name string, results *tap13.Results) int {
+	count := 0
+	for _, problem := range results.Problems {
+		fmt.Fprintf(w, "%s: %s\n", name, problem)
+		count++
+	}
+	for _, test := range results.Tests {
+		if test.Subtests != nil {
+			count += printProblems(w, name, test.Subtests)
+		}
+	}
+	return count
+}
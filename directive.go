@@ -0,0 +1,76 @@
+package tap13
+
+import (
+	"regexp"
+	"strings"
+)
+
+// DirectiveKind is the kind of directive found after the "#" on a test line or plan.
+type DirectiveKind int
+
+const (
+	// DirectiveNone indicates that there was no directive.
+	DirectiveNone DirectiveKind = iota
+	// DirectiveSkip indicates a SKIP directive, such as "# SKIP no database" or "# skipped: slow".
+	DirectiveSkip
+	// DirectiveTodo indicates a TODO directive, such as "# TODO not implemented" or "# TODO: later".
+	DirectiveTodo
+	// DirectiveUnknown indicates a comment which is not a recognized directive.
+	DirectiveUnknown
+)
+
+var directiveKindNames = map[DirectiveKind]string{
+	DirectiveNone:    "none",
+	DirectiveSkip:    "skip",
+	DirectiveTodo:    "todo",
+	DirectiveUnknown: "unknown",
+}
+
+func (k DirectiveKind) String() string {
+	if name, ok := directiveKindNames[k]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Directive is a directive found after the "#" on a test line or plan, split into its kind and
+// reason. The Text field contains the whole text of the directive, including the keyword, as found
+// in Test.DirectiveText.
+type Directive struct {
+	Kind   DirectiveKind
+	Reason string
+	Text   string
+}
+
+// directiveKeyword matches the keyword of a directive, and the punctuation which may separate it
+// from the reason. As with Test::More, any word beginning with "skip" (such as "skipped") is a SKIP
+// directive; node-tap and pytest-tap also write "SKIP:" and "TODO:".
+var directiveKeyword = regexp.MustCompile(`^(?i:(skip\w*)|(todo))(?:\s*[:\-]\s*|\s+|$)`)
+
+// ParseDirective interprets the specified directive text, which follows the "#" on a test line or
+// plan. The keyword is not case sensitive. If the text is not a SKIP or TODO directive, the kind of
+// the returned Directive is DirectiveUnknown (or DirectiveNone, if the text is empty), and its
+// reason is empty.
+func ParseDirective(text string) Directive {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return Directive{Kind: DirectiveNone}
+	}
+	directive := Directive{Kind: DirectiveUnknown, Text: text}
+	match := directiveKeyword.FindStringSubmatchIndex(text)
+	if match == nil {
+		return directive
+	}
+	if match[2] >= 0 {
+		directive.Kind = DirectiveSkip
+	} else {
+		directive.Kind = DirectiveTodo
+	}
+	directive.Reason = strings.TrimSpace(text[match[1]:])
+	return directive
+}
+
+// Directive returns the directive of the test, as parsed from the DirectiveText field.
+func (t *Test) Directive() Directive {
+	return ParseDirective(t.DirectiveText)
+}
@@ -0,0 +1,74 @@
+package tap13
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDirective(t *testing.T) {
+	t.Run("ParsesKindAndReason", func(t *testing.T) {
+		assert.Equal(t, Directive{Kind: DirectiveNone}, ParseDirective(""))
+		assert.Equal(t, Directive{Kind: DirectiveSkip, Text: "SKIP"}, ParseDirective("SKIP"))
+		assert.Equal(t,
+			Directive{Kind: DirectiveSkip, Reason: "no database", Text: "SKIP no database"},
+			ParseDirective("SKIP no database"))
+		assert.Equal(t,
+			Directive{Kind: DirectiveSkip, Reason: "too slow", Text: "skipped: too slow"},
+			ParseDirective("skipped: too slow"))
+		assert.Equal(t,
+			Directive{Kind: DirectiveSkip, Reason: "on Windows", Text: "Skipping - on Windows"},
+			ParseDirective(" Skipping - on Windows "))
+		assert.Equal(t,
+			Directive{Kind: DirectiveTodo, Reason: "working on it", Text: "TODO: working on it"},
+			ParseDirective("TODO: working on it"))
+		assert.Equal(t,
+			Directive{Kind: DirectiveTodo, Reason: "later", Text: "todo later"},
+			ParseDirective("todo later"))
+		assert.Equal(t,
+			Directive{Kind: DirectiveUnknown, Text: "todos are fun"},
+			ParseDirective("todos are fun"))
+		assert.Equal(t,
+			Directive{Kind: DirectiveUnknown, Text: "squirrel!"},
+			ParseDirective("squirrel!"))
+	})
+	t.Run("RecognizesVariantsOnTestLines", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+1..5
+ok 1 - a # skipped: not on this platform
+not ok 2 - b # TODO: not implemented
+ok 3 - c # Skip
+ok 4 - d # squirrel!
+ok 5 - e`,
+			"\n")
+		result := Parse(input)
+		assert.True(t, result.IsPassing())
+		assert.Equal(t, 2, result.SkippedTests)
+		assert.Equal(t, 1, result.TodoTests)
+		var kinds []DirectiveKind
+		var reasons []string
+		for i := range result.Tests {
+			directive := result.Tests[i].Directive()
+			kinds = append(kinds, directive.Kind)
+			reasons = append(reasons, directive.Reason)
+		}
+		assert.Equal(t, []DirectiveKind{
+			DirectiveSkip, DirectiveTodo, DirectiveSkip, DirectiveUnknown, DirectiveNone,
+		}, kinds)
+		assert.Equal(t, []string{"not on this platform", "not implemented", "", "", ""}, reasons)
+		assert.Equal(t, "skipped: not on this platform", result.Tests[0].DirectiveText)
+	})
+	t.Run("RecognizesVariantsOnPlans", func(t *testing.T) {
+		result := Parse([]string{"TAP version 13", "1..0 # Skipped: no database"})
+		assert.True(t, result.PlanSkipped)
+		assert.Equal(t, "no database", result.PlanSkipReason)
+	})
+	t.Run("FormatsAsString", func(t *testing.T) {
+		assert.Equal(t, "none", DirectiveNone.String())
+		assert.Equal(t, "skip", DirectiveSkip.String())
+		assert.Equal(t, "todo", DirectiveTodo.String())
+		assert.Equal(t, "unknown", DirectiveUnknown.String())
+		assert.Equal(t, "unknown", DirectiveKind(99).String())
+	})
+}
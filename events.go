@@ -0,0 +1,78 @@
+package tap13
+
+// EventType identifies the kind of TAP element an Event describes.
+type EventType int
+
+const (
+	// UnknownEvent is emitted for any line that could not be interpreted as a TAP element,
+	// including any lines which appear before the TAP version line.
+	UnknownEvent EventType = iota
+	// VersionEvent is emitted when the TAP version line is found.
+	VersionEvent
+	// PlanEvent is emitted when a test plan (such as "1..4") is found.
+	PlanEvent
+	// TestEvent is emitted for each test line ("ok" or "not ok").
+	TestEvent
+	// DiagnosticEvent is emitted for each non-blank diagnostic line (or for each diagnostic line,
+	// if ParseOptions.KeepBlankDiagnostics is set).
+	DiagnosticEvent
+	// YamlEvent is emitted when a YAML block has been terminated.
+	YamlEvent
+	// BailOutEvent is emitted when a "Bail out!" line is found.
+	BailOutEvent
+	// SubtestEvent is emitted when a subtest begins.
+	SubtestEvent
+	// PragmaEvent is emitted for each pragma found in a pragma line.
+	PragmaEvent
+)
+
+var eventTypeNames = map[EventType]string{
+	UnknownEvent:    "unknown",
+	VersionEvent:    "version",
+	PlanEvent:       "plan",
+	TestEvent:       "test",
+	DiagnosticEvent: "diagnostic",
+	YamlEvent:       "yaml",
+	BailOutEvent:    "bail out",
+	SubtestEvent:    "subtest",
+	PragmaEvent:     "pragma",
+}
+
+func (t EventType) String() string {
+	if name, ok := eventTypeNames[t]; ok {
+		return name
+	}
+	return "invalid"
+}
+
+// Event describes a single TAP element, as it is interpreted by a Parser. The Line field contains
+// the 1-based line number of the input line which completed the element, and Text contains the
+// raw text of that line. Only the fields relevant to the event Type are populated:
+//
+//   - VersionEvent: Version
+//   - PlanEvent: ExpectedTests
+//   - TestEvent: Test
+//   - DiagnosticEvent: Diagnostic, and Test (if the diagnostic follows a test line)
+//   - YamlEvent: YamlBytes, and Test (if the YAML block follows a test line)
+//   - BailOutEvent: BailOutReason
+//   - SubtestEvent: Subtest (the name of the subtest, if one was given)
+//   - PragmaEvent: Pragma
+//
+// Events from within a subtest are passed to the same handler, with the Depth field indicating
+// how deeply the subtest is nested. Events for the top-level TAP stream have a Depth of zero.
+// The Test field points to a copy of the test as it was when the event was emitted; it will not
+// reflect diagnostics or YAML which arrive later.
+type Event struct {
+	Type          EventType
+	Line          int
+	Text          string
+	Version       int
+	ExpectedTests int
+	Test          *Test
+	Diagnostic    string
+	YamlBytes     []byte
+	BailOutReason string
+	Subtest       string
+	Pragma        Pragma
+	Depth         int
+}
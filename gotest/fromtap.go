@@ -0,0 +1,138 @@
+package gotest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mpontillo/tap13"
+)
+
+// eventWriter writes the events corresponding to TAP results for a single package.
+type eventWriter struct {
+	encoder *json.Encoder
+	pkg     string
+	err     error
+}
+
+func (w *eventWriter) write(event Event) {
+	if w.err != nil {
+		return
+	}
+	event.Package = w.pkg
+	w.err = w.encoder.Encode(&event)
+}
+
+func (w *eventWriter) output(test string, output string) {
+	w.write(Event{Action: ActionOutput, Test: test, Output: output})
+}
+
+// WriteEvents writes the specified results to w as a "go test -json" event stream, as if they were
+// the results of testing the specified package. Each Test is written as a Go test (and each of its
+// subtests, if any, as a Go subtest), with a "run" action, "output" actions for its diagnostics and
+// YAML diagnostic block, and a "pass", "fail" or "skip" action. TODO tests are written as skipped.
+// The package passes if the results are passing, or is skipped if the plan skipped all the tests.
+func WriteEvents(w io.Writer, pkg string, results *tap13.Results) error {
+	writer := &eventWriter{encoder: json.NewEncoder(w), pkg: pkg}
+	writer.write(Event{Action: ActionStart})
+	for _, line := range results.Explanation {
+		writer.output("", line+"\n")
+	}
+	writeTests(writer, "", results)
+	if !results.FoundTapData {
+		writer.output("", "no TAP output found\n")
+	}
+	if missing := results.ExpectedTests - results.TotalTests; missing > 0 {
+		writer.output("", fmt.Sprintf("%d planned tests were not run\n", missing))
+	}
+	if results.BailOut {
+		writer.output("", strings.TrimSpace("Bail out! "+results.BailOutReason)+"\n")
+	}
+	if results.PlanSkipped && results.IsPassing() {
+		if results.PlanSkipReason != "" {
+			writer.output("", "SKIP: "+results.PlanSkipReason+"\n")
+		}
+		writer.output("", fmt.Sprintf("?   \t%s\t[no tests to run]\n", pkg))
+		writer.write(Event{Action: ActionSkip})
+	} else if results.IsPassing() {
+		writer.output("", "PASS\n")
+		writer.output("", fmt.Sprintf("ok  \t%s\t0.000s\n", pkg))
+		writer.write(Event{Action: ActionPass})
+	} else {
+		writer.output("", "FAIL\n")
+		writer.output("", fmt.Sprintf("FAIL\t%s\t0.000s\n", pkg))
+		writer.write(Event{Action: ActionFail})
+	}
+	return writer.err
+}
+
+// writeTests writes the events for each test in the specified results. The parent is the name of
+// the Go test corresponding to the enclosing test, if the results are those of a subtest.
+func writeTests(writer *eventWriter, parent string, results *tap13.Results) {
+	names := map[string]int{}
+	for i := range results.Tests {
+		test := &results.Tests[i]
+		if test.Extra {
+			// Tests beyond the plan are not part of the run.
+			continue
+		}
+		name := testName(test, i)
+		// Disambiguate duplicate names in the same way the testing package does.
+		if count := names[name]; count > 0 {
+			names[name]++
+			name = fmt.Sprintf("%s#%02d", name, count)
+		} else {
+			names[name] = 1
+		}
+		if parent != "" {
+			name = parent + "/" + name
+		}
+		writeTest(writer, name, test)
+	}
+}
+
+// testName returns the name of the Go test corresponding to the specified test, which has the
+// specified index in the results. As with Go subtests, spaces are replaced with underscores.
+func testName(test *tap13.Test, index int) string {
+	name := strings.TrimSpace(strings.TrimPrefix(test.Description, "- "))
+	if name == "" {
+		number := test.TestNumber
+		if number <= 0 {
+			number = index + 1
+		}
+		name = fmt.Sprintf("test %d", number)
+	}
+	return strings.Join(strings.Fields(name), "_")
+}
+
+// writeTest writes the events for the specified test, and its subtests (if any).
+func writeTest(writer *eventWriter, name string, test *tap13.Test) {
+	writer.write(Event{Action: ActionRun, Test: name})
+	writer.output(name, fmt.Sprintf("=== RUN   %s\n", name))
+	if test.Subtests != nil {
+		writeTests(writer, name, test.Subtests)
+	}
+	for _, line := range test.Diagnostics {
+		writer.output(name, fmt.Sprintf("    %s\n", line))
+	}
+	if len(test.YamlBytes) > 0 {
+		for _, line := range strings.Split(strings.TrimSuffix(string(test.YamlBytes), "\n"), "\n") {
+			writer.output(name, fmt.Sprintf("    %s\n", line))
+		}
+	}
+	var elapsed float64
+	if diagnostic, err := test.Diagnostic(); err == nil && diagnostic != nil {
+		elapsed = diagnostic.DurationMS / 1000
+	}
+	action, status := ActionPass, "PASS"
+	switch {
+	case test.Skipped || test.Todo:
+		action, status = ActionSkip, "SKIP"
+		writer.output(name, fmt.Sprintf("    %s\n", test.DirectiveText))
+	case test.Failed:
+		action, status = ActionFail, "FAIL"
+	}
+	writer.output(name, fmt.Sprintf("--- %s: %s (%.2fs)\n", status, name, elapsed))
+	writer.write(Event{Action: action, Test: name, Elapsed: elapsed})
+}
@@ -0,0 +1,118 @@
+package gotest
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mpontillo/tap13"
+)
+
+func decodeEvents(t *testing.T, data []byte) []Event {
+	var events []Event
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for decoder.More() {
+		var event Event
+		assert.NoError(t, decoder.Decode(&event))
+		events = append(events, event)
+	}
+	return events
+}
+
+func TestWriteEvents(t *testing.T) {
+	t.Run("WritesEventsForEachTest", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+1..4
+ok 1 - first test
+not ok 2 - first test
+# went wrong
+  ---
+  duration_ms: 1500
+  ...
+ok 3 # SKIP not today
+not ok 4 - later # TODO`,
+			"\n")
+		var output bytes.Buffer
+		assert.NoError(t, WriteEvents(&output, "example", tap13.Parse(input)))
+		events := decodeEvents(t, output.Bytes())
+		var actions []string
+		for _, event := range events {
+			assert.Equal(t, "example", event.Package)
+			if event.Action != ActionOutput {
+				actions = append(actions, event.Action+" "+event.Test)
+			}
+		}
+		assert.Equal(t, []string{
+			"start ",
+			"run first_test",
+			"pass first_test",
+			"run first_test#01",
+			"fail first_test#01",
+			"run test_3",
+			"skip test_3",
+			"run later",
+			"skip later",
+			"fail ",
+		}, actions)
+		var failureOutput string
+		for _, event := range events {
+			if event.Test == "first_test#01" {
+				failureOutput += event.Output
+				if event.Action == ActionFail {
+					assert.Equal(t, 1.5, event.Elapsed)
+				}
+			}
+		}
+		assert.Equal(t, `=== RUN   first_test#01
+    went wrong
+      duration_ms: 1500
+--- FAIL: first_test#01 (1.50s)
+`, failureOutput)
+	})
+	t.Run("WritesSubtestsAsGoSubtests", func(t *testing.T) {
+		input := strings.Split(`TAP version 14
+# Subtest: outer
+    ok 1 - inner
+ok 1 - outer`,
+			"\n")
+		var output bytes.Buffer
+		assert.NoError(t, WriteEvents(&output, "example", tap13.Parse(input)))
+		var tests []string
+		for _, event := range decodeEvents(t, output.Bytes()) {
+			if event.Action == ActionRun {
+				tests = append(tests, event.Test)
+			}
+		}
+		assert.Equal(t, []string{"outer", "outer/inner"}, tests)
+	})
+	t.Run("WritesSkippedPlanAsSkippedPackage", func(t *testing.T) {
+		input := []string{"TAP version 13", "1..0 # SKIP no database"}
+		var output bytes.Buffer
+		assert.NoError(t, WriteEvents(&output, "example", tap13.Parse(input)))
+		events := decodeEvents(t, output.Bytes())
+		assert.Equal(t, ActionSkip, events[len(events)-1].Action)
+		assert.Equal(t, "SKIP: no database\n", events[1].Output)
+	})
+	t.Run("RoundTripsThroughToTAP", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+1..5
+ok 1 - a
+not ok 2 - b
+ok 3 - c # SKIP
+ok 4 - d
+Bail out!`,
+			"\n")
+		var events bytes.Buffer
+		assert.NoError(t, WriteEvents(&events, "example", tap13.Parse(input)))
+		var tap bytes.Buffer
+		assert.NoError(t, ToTAP(&events, &tap, Options{}))
+		result := tap13.Parse(strings.Split(strings.TrimSuffix(tap.String(), "\n"), "\n"))
+		assert.Equal(t, 4, result.TotalTests)
+		assert.Equal(t, 2, result.PassedTests)
+		assert.Equal(t, 1, result.FailedTests)
+		assert.Equal(t, 1, result.SkippedTests)
+	})
+}
@@ -0,0 +1,34 @@
+/*
+Package gotest converts between TAP and the JSON event stream produced by "go test -json" (as
+documented by "go doc test2json").
+*/
+package gotest
+
+import "time"
+
+// Event is a single event in the JSON event stream produced by "go test -json".
+type Event struct {
+	Time    *time.Time `json:",omitempty"`
+	Action  string
+	Package string  `json:",omitempty"`
+	Test    string  `json:",omitempty"`
+	Elapsed float64 `json:",omitempty"`
+	Output  string  `json:",omitempty"`
+}
+
+// Actions used in the Action field of an Event.
+const (
+	ActionStart  = "start"
+	ActionRun    = "run"
+	ActionPause  = "pause"
+	ActionCont   = "cont"
+	ActionPass   = "pass"
+	ActionBench  = "bench"
+	ActionFail   = "fail"
+	ActionOutput = "output"
+	ActionSkip   = "skip"
+	// ActionBuildOutput and ActionBuildFail are used by Go 1.24 and later to report the output
+	// of the build; these events have no Package.
+	ActionBuildOutput = "build-output"
+	ActionBuildFail   = "build-fail"
+)
@@ -0,0 +1,220 @@
+package gotest
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/mpontillo/tap13"
+)
+
+// Options control how a "go test -json" event stream is converted to TAP.
+type Options struct {
+	// Subtests causes each package to be written as a subtest containing a test for each Go test
+	// in the package. Otherwise, a single flat TAP stream is written, with a test for each Go test
+	// (whose description is prefixed with the package name).
+	Subtests bool
+}
+
+// failure is written as the YAML diagnostic block of each failing test.
+type failure struct {
+	Message    string  `yaml:"message"`
+	Severity   string  `yaml:"severity"`
+	Output     string  `yaml:"output,omitempty"`
+	DurationMS float64 `yaml:"duration_ms"`
+}
+
+// goTest holds the state of a Go test (or package) while its events are being converted.
+type goTest struct {
+	name    string
+	action  string
+	elapsed float64
+	output  []string
+}
+
+// goPackage holds the state of a package while its events are being converted.
+type goPackage struct {
+	name       string
+	tests      map[string]*goTest
+	finished   []*goTest
+	failed     bool
+	testFailed bool
+	elapsed    float64
+	output     []string
+}
+
+// converter converts a "go test -json" event stream into TAP.
+type converter struct {
+	options  Options
+	writer   *tap13.Writer
+	packages map[string]*goPackage
+	order    []string
+}
+
+// ToTAP reads the "go test -json" event stream from r, and writes the corresponding TAP to w. Each
+// Go test (including subtests, such as "TestFoo/bar") is written as a test, with "pass", "fail"
+// and "skip" actions corresponding to passing, failing and skipped tests. The output of each
+// failing test is written in its YAML diagnostic block, and the output of each skipped test is
+// used as the reason for the skip. If a package fails without any of its tests failing (such as
+// when it cannot be built), an additional failing test is written for the package. Any lines in
+// the input which are not JSON events are written as diagnostics.
+func ToTAP(r io.Reader, w io.Writer, options Options) error {
+	c := &converter{
+		options:  options,
+		writer:   tap13.NewWriter(w),
+		packages: map[string]*goPackage{},
+	}
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			if writeErr := c.convert(strings.TrimRight(line, "\r\n")); writeErr != nil {
+				return writeErr
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	// Packages which never finished (for example, because the input was truncated) are written
+	// as they are.
+	for _, name := range c.order {
+		if p, ok := c.packages[name]; ok {
+			if err := c.writePackage(p); err != nil {
+				return err
+			}
+		}
+	}
+	return c.writer.Done()
+}
+
+func (c *converter) convert(line string) error {
+	var event Event
+	if !strings.HasPrefix(line, "{") || json.Unmarshal([]byte(line), &event) != nil {
+		if strings.TrimSpace(line) == "" {
+			return nil
+		}
+		return c.writer.Diag(line)
+	}
+	if event.Action == ActionBuildOutput {
+		return c.writer.Diag(strings.TrimRight(event.Output, "\n"))
+	}
+	if event.Package == "" {
+		return nil
+	}
+	p := c.packages[event.Package]
+	if p == nil {
+		p = &goPackage{name: event.Package, tests: map[string]*goTest{}}
+		c.packages[event.Package] = p
+		c.order = append(c.order, event.Package)
+	}
+	if event.Test == "" {
+		switch event.Action {
+		case ActionOutput:
+			p.output = append(p.output, event.Output)
+		case ActionPass, ActionSkip, ActionFail:
+			p.failed = event.Action == ActionFail
+			p.elapsed = event.Elapsed
+			delete(c.packages, event.Package)
+			return c.writePackage(p)
+		}
+		return nil
+	}
+	test := p.tests[event.Test]
+	if test == nil {
+		test = &goTest{name: event.Test}
+		p.tests[event.Test] = test
+	}
+	switch event.Action {
+	case ActionOutput:
+		test.output = append(test.output, event.Output)
+	case ActionPass, ActionSkip, ActionFail:
+		test.action = event.Action
+		test.elapsed = event.Elapsed
+		p.testFailed = p.testFailed || event.Action == ActionFail
+		delete(p.tests, event.Test)
+		if c.options.Subtests {
+			p.finished = append(p.finished, test)
+			return nil
+		}
+		return c.writeTest(c.writer, p.name+" "+test.name, test)
+	}
+	return nil
+}
+
+// writePackage writes the tests in the specified package which have not already been written,
+// along with a test for the package itself (if necessary).
+func (c *converter) writePackage(p *goPackage) error {
+	packageTest := &goTest{action: ActionFail, elapsed: p.elapsed, output: p.output}
+	if !c.options.Subtests {
+		if p.failed && !p.testFailed {
+			return c.writeTest(c.writer, p.name, packageTest)
+		}
+		return nil
+	}
+	if len(p.finished) == 0 {
+		if p.failed {
+			return c.writeTest(c.writer, p.name, packageTest)
+		}
+		return c.writer.Skip(p.name, "no tests")
+	}
+	subtest := c.writer.Subtest(p.name)
+	for _, test := range p.finished {
+		if err := c.writeTest(subtest, test.name, test); err != nil {
+			return err
+		}
+	}
+	if err := subtest.Done(); err != nil {
+		return err
+	}
+	if p.failed || p.testFailed {
+		return c.writeTest(c.writer, p.name, packageTest)
+	}
+	return c.writer.Ok(p.name)
+}
+
+// writeTest writes a test with the specified description, corresponding to the result of the
+// specified Go test.
+func (c *converter) writeTest(w *tap13.Writer, description string, test *goTest) error {
+	output := filterOutput(test.output)
+	switch test.action {
+	case ActionPass:
+		return w.Ok(description)
+	case ActionSkip:
+		reason := ""
+		if len(output) > 0 {
+			reason = strings.TrimSpace(output[len(output)-1])
+		}
+		return w.Skip(description, reason)
+	}
+	return w.NotOk(description, &failure{
+		Message:    "test failed",
+		Severity:   "fail",
+		Output:     strings.Join(output, ""),
+		DurationMS: test.elapsed * 1000,
+	})
+}
+
+// filterOutput removes the lines written by the testing package to mark the progress of each
+// test (such as "=== RUN" and "--- PASS"), which are represented by the TAP output itself.
+func filterOutput(output []string) []string {
+	var filtered []string
+	for _, line := range output {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "=== "),
+			strings.HasPrefix(trimmed, "--- PASS"),
+			strings.HasPrefix(trimmed, "--- FAIL"),
+			strings.HasPrefix(trimmed, "--- SKIP"),
+			trimmed == "PASS",
+			trimmed == "FAIL":
+			continue
+		}
+		filtered = append(filtered, line)
+	}
+	return filtered
+}
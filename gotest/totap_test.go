@@ -0,0 +1,82 @@
+package gotest
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mpontillo/tap13"
+)
+
+func convertTestData(t *testing.T, options Options) *tap13.Results {
+	input, err := os.Open("testdata/go_test.json")
+	assert.NoError(t, err)
+	defer input.Close()
+	var output bytes.Buffer
+	assert.NoError(t, ToTAP(input, &output, options))
+	return tap13.Parse(strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n"))
+}
+
+func TestToTAP(t *testing.T) {
+	t.Run("WritesFlatStream", func(t *testing.T) {
+		result := convertTestData(t, Options{})
+		assert.Empty(t, result.Problems)
+		assert.Equal(t, 13, result.TapVersion)
+		assert.Equal(t, 7, result.ExpectedTests)
+		assert.Equal(t, 7, result.TotalTests)
+		assert.Equal(t, 2, result.PassedTests)
+		assert.Equal(t, 4, result.FailedTests)
+		assert.Equal(t, 1, result.SkippedTests)
+		assert.Equal(t, []string{
+			"# example.com/gt/r",
+			"r/r_test.go:2:9: expected ')', found '{'",
+		}, result.Explanation)
+		assert.Equal(t, "example.com/gt/r", result.Tests[0].Description)
+		assert.True(t, result.Tests[0].Failed)
+		assert.Equal(t, "example.com/gt/p TestPass", result.Tests[1].Description)
+		diagnostic, err := result.Tests[2].Diagnostic()
+		assert.NoError(t, err)
+		assert.Equal(t, "test failed", diagnostic.Message)
+		yaml, err := result.Tests[2].YAML()
+		assert.NoError(t, err)
+		assert.Equal(t, "    p_test.go:4: boom\n        second line\n", yaml["output"])
+		assert.Equal(t, "SKIP p_test.go:5: not today", result.Tests[3].DirectiveText)
+	})
+	t.Run("WritesPackagesAsSubtests", func(t *testing.T) {
+		result := convertTestData(t, Options{Subtests: true})
+		assert.Empty(t, result.Problems)
+		assert.Equal(t, 3, result.TotalTests)
+		assert.Equal(t, 2, result.FailedTests)
+		assert.Equal(t, 1, result.SkippedTests)
+		assert.Nil(t, result.Tests[0].Subtests)
+		p := result.Tests[1].Subtests
+		assert.Equal(t, "example.com/gt/p", p.Name)
+		assert.Equal(t, 6, p.ExpectedTests)
+		assert.Equal(t, 2, p.PassedTests)
+		assert.Equal(t, 3, p.FailedTests)
+		assert.Equal(t, 1, p.SkippedTests)
+		assert.Equal(t, "TestPass", p.Tests[0].Description)
+		assert.Equal(t, "SKIP no tests", result.Tests[2].DirectiveText)
+	})
+	t.Run("WritesUnfinishedPackages", func(t *testing.T) {
+		input := strings.Join([]string{
+			`{"Action":"run","Package":"p","Test":"TestA"}`,
+			`{"Action":"pass","Package":"p","Test":"TestA"}`,
+			`{"Action":"run","Package":"p","Test":"TestB"}`,
+			`not JSON`,
+		}, "\n")
+		var output bytes.Buffer
+		assert.NoError(t, ToTAP(strings.NewReader(input), &output, Options{Subtests: true}))
+		assert.Equal(t, `TAP version 13
+# not JSON
+# Subtest: p
+    ok 1 TestA
+    1..1
+ok 1 p
+1..1
+`, output.String())
+	})
+}
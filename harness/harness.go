@@ -0,0 +1,300 @@
+/*
+Package harness runs test programs which produce TAP output, in the style of Perl's "prove"
+command, and collects their results.
+
+Each test program is executed with its standard output parsed as TAP, and its standard error
+captured separately. The program is started using the interpreter configured for its file
+extension (if any), or else the interpreter named on its "#!" line (if any), or else executed
+directly.
+*/
+package harness
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mpontillo/tap13"
+)
+
+// Runner runs test programs and collects their results. The zero value runs one test program at
+// a time, with no timeout.
+type Runner struct {
+	// Jobs is the maximum number of test programs to run concurrently. Values less than one are
+	// treated as one.
+	Jobs int
+	// Timeout is the maximum duration of each test program; zero means no timeout.
+	Timeout time.Duration
+	// Interpreters maps a file extension (such as ".py") to the command line used to run files
+	// with that extension (such as []string{"python3"}). The path of the test program is appended
+	// to the command line.
+	Interpreters map[string][]string
+	// Options controls how the TAP output of each test program is parsed.
+	Options tap13.ParseOptions
+	// FailFast causes all remaining test programs to be stopped (or not started) as soon as any
+	// test program bails out.
+	FailFast bool
+	// Progress, if not nil, is called as each test program starts, as each TAP element in its
+	// output is parsed, and when it finishes. It may be called concurrently from multiple
+	// goroutines when more than one job is run at a time.
+	Progress func(Progress)
+}
+
+// Progress describes the progress of a test program being run by a Runner. Exactly one of Started,
+// Event and Result is set.
+type Progress struct {
+	// Index is the position of the test program in the list of test programs being run.
+	Index int
+	// Path is the path of the test program.
+	Path string
+	// Started is set when the test program is about to be started.
+	Started bool
+	// Event contains a TAP element parsed from the output of the test program.
+	Event *tap13.Event
+	// Result contains the result of the test program, once it has finished.
+	Result *Result
+}
+
+// Result contains the outcome of running a single test program.
+type Result struct {
+	// Path is the path of the test program.
+	Path string
+	// Results contains the parsed TAP output of the test program.
+	Results *tap13.Results
+	// Stderr contains the standard error output of the test program.
+	Stderr []byte
+	// ExitCode is the exit status of the test program, or -1 if it did not exit normally.
+	ExitCode int
+	// Duration is the time taken to run the test program.
+	Duration time.Duration
+	// TimedOut is set if the test program was stopped because the Timeout elapsed.
+	TimedOut bool
+	// Canceled is set if the test program was stopped (or was never started) because the context
+	// was canceled, or because another test program bailed out and FailFast is set.
+	Canceled bool
+	// Err contains any error which prevented the test program from being run, or any error
+	// reading its output.
+	Err error
+}
+
+// IsPassing checks if the test program ran successfully, exited with a zero exit status, and
+// produced passing TAP output.
+func (r *Result) IsPassing() bool {
+	return r.Err == nil && !r.TimedOut && !r.Canceled && r.ExitCode == 0 &&
+		r.Results.IsPassing()
+}
+
+// Expand returns the test programs found at the specified paths. Each path which is a directory is
+// replaced with the (sorted) regular files it contains, excluding hidden files.
+func Expand(paths []string) ([]string, error) {
+	var expanded []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			expanded = append(expanded, path)
+			continue
+		}
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		var files []string
+		for _, entry := range entries {
+			if entry.Mode().IsRegular() && !strings.HasPrefix(entry.Name(), ".") {
+				files = append(files, filepath.Join(path, entry.Name()))
+			}
+		}
+		sort.Strings(files)
+		expanded = append(expanded, files...)
+	}
+	return expanded, nil
+}
+
+// Command returns the command line used to run the specified test program.
+func (r *Runner) Command(path string) []string {
+	if interpreter, ok := r.Interpreters[filepath.Ext(path)]; ok && len(interpreter) > 0 {
+		return append(append([]string{}, interpreter...), path)
+	}
+	if interpreter := shebang(path); len(interpreter) > 0 {
+		return append(interpreter, path)
+	}
+	if !strings.ContainsRune(path, filepath.Separator) {
+		// Make sure the test program isn't looked up in the PATH.
+		path = "." + string(filepath.Separator) + path
+	}
+	return []string{path}
+}
+
+// shebang returns the interpreter named on the "#!" line of the specified file, if any.
+func shebang(path string) []string {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+	line, err := bufio.NewReader(file).ReadString('\n')
+	if err != nil && line == "" {
+		return nil
+	}
+	if !strings.HasPrefix(line, "#!") {
+		return nil
+	}
+	return strings.Fields(line[2:])
+}
+
+// Run runs each of the specified test programs, and returns their results in the same order.
+func (r *Runner) Run(ctx context.Context, paths []string) []Result {
+	jobs := r.Jobs
+	if jobs < 1 {
+		jobs = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var bailOut func()
+	if r.FailFast {
+		bailOut = cancel
+	}
+	results := make([]Result, len(paths))
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < jobs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				results[index] = r.run(ctx, index, paths[index], bailOut)
+			}
+		}()
+	}
+	for index := range paths {
+		indexes <- index
+	}
+	close(indexes)
+	wg.Wait()
+	return results
+}
+
+// RunOne runs the specified test program and returns its result.
+func (r *Runner) RunOne(ctx context.Context, path string) Result {
+	return r.run(ctx, 0, path, nil)
+}
+
+// run runs the test program at the specified index, reporting its progress. If bailOut is not
+// nil, it is called as soon as the test program bails out.
+func (r *Runner) run(ctx context.Context, index int, path string, bailOut func()) (result Result) {
+	result = Result{Path: path, ExitCode: -1}
+	parent := ctx
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+	var handler func(tap13.Event)
+	if r.Progress != nil || bailOut != nil {
+		handler = func(event tap13.Event) {
+			if r.Progress != nil {
+				r.Progress(Progress{Index: index, Path: path, Event: &event})
+			}
+			if event.Type == tap13.BailOutEvent && bailOut != nil {
+				bailOut()
+			}
+		}
+	}
+	parser := tap13.NewParserWithOptions(r.Options, handler)
+	defer func() {
+		result.Results = parser.Finish()
+		if r.Progress != nil {
+			r.Progress(Progress{Index: index, Path: path, Result: &result})
+		}
+	}()
+	if parent.Err() != nil {
+		result.Canceled = true
+		return result
+	}
+	if r.Progress != nil {
+		r.Progress(Progress{Index: index, Path: path, Started: true})
+	}
+	commandLine := r.Command(path)
+	cmd := exec.CommandContext(ctx, commandLine[0], commandLine[1:]...)
+	// Pipes are used (rather than letting the exec package copy the output) so that reading the
+	// output can be abandoned if the test program is stopped while its children hold them open.
+	stdout, stdoutWriter, err := os.Pipe()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer stdout.Close()
+	stderrReader, stderrWriter, err := os.Pipe()
+	if err != nil {
+		stdoutWriter.Close()
+		result.Err = err
+		return result
+	}
+	defer stderrReader.Close()
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
+	start := time.Now()
+	err = cmd.Start()
+	stdoutWriter.Close()
+	stderrWriter.Close()
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	readDone := make(chan error, 1)
+	go func() {
+		_, err := parser.ReadFrom(stdout)
+		readDone <- err
+	}()
+	var stderr bytes.Buffer
+	stderrDone := make(chan error, 1)
+	go func() {
+		_, err := io.Copy(&stderr, stderrReader)
+		stderrDone <- err
+	}()
+	err = cmd.Wait()
+	readErr := waitForOutput(ctx, stdout, readDone)
+	waitForOutput(ctx, stderrReader, stderrDone)
+	result.Duration = time.Since(start)
+	result.Stderr = stderr.Bytes()
+	result.ExitCode = cmd.ProcessState.ExitCode()
+	if parent.Err() != nil && !parser.Results().BailOut {
+		result.Canceled = true
+	} else if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		result.TimedOut = true
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		result.Err = err
+	} else if readErr != nil {
+		result.Err = readErr
+	}
+	return result
+}
+
+// waitForOutput waits until the output of a test program has been read, and returns any error
+// which occurred while reading it. If the context is done, the pipe is closed to stop reading,
+// since the output may never end (if the pipe is held open by a child of the test program).
+func waitForOutput(ctx context.Context, pipe *os.File, done chan error) error {
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		pipe.Close()
+		<-done
+		return nil
+	}
+}
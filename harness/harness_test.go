@@ -0,0 +1,146 @@
+package harness
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mpontillo/tap13"
+)
+
+// writeScripts creates a temporary directory containing the specified shell scripts, and returns
+// its path.
+func writeScripts(t *testing.T, scripts map[string]string) string {
+	dir, err := ioutil.TempDir("", "harness")
+	assert.NoError(t, err)
+	for name, script := range scripts {
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0755)
+		assert.NoError(t, err)
+	}
+	return dir
+}
+
+func TestRunner(t *testing.T) {
+	dir := writeScripts(t, map[string]string{
+		"pass.sh":    "#!/bin/sh\necho 'TAP version 13'\necho '1..2'\necho 'ok 1'\necho 'ok 2'\n",
+		"fail.sh":    "#!/bin/sh\necho 'TAP version 13'\necho 'not ok 1'\necho 'oops' >&2\n",
+		"exit.sh":    "#!/bin/sh\necho 'TAP version 13'\necho 'ok 1'\nexit 3\n",
+		"slow.sh":    "#!/bin/sh\nexec sleep 10\n",
+		"noshebang":  "echo 'TAP version 13'\necho 'ok 1'\n",
+		".hidden.sh": "#!/bin/sh\nexit 1\n",
+		"bail.sh":    "#!/bin/sh\necho 'TAP version 13'\necho 'Bail out!'\nexec sleep 10\n",
+		"sleeper.sh": "#!/bin/sh\necho 'TAP version 13'\nsleep 10\necho 'ok 1'\n",
+	})
+	defer os.RemoveAll(dir)
+	t.Run("ExpandsDirectories", func(t *testing.T) {
+		paths, err := Expand([]string{dir, filepath.Join(dir, "pass.sh")})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			filepath.Join(dir, "bail.sh"),
+			filepath.Join(dir, "exit.sh"),
+			filepath.Join(dir, "fail.sh"),
+			filepath.Join(dir, "noshebang"),
+			filepath.Join(dir, "pass.sh"),
+			filepath.Join(dir, "sleeper.sh"),
+			filepath.Join(dir, "slow.sh"),
+			filepath.Join(dir, "pass.sh"),
+		}, paths)
+		_, err = Expand([]string{filepath.Join(dir, "missing")})
+		assert.Error(t, err)
+	})
+	t.Run("ChoosesInterpreter", func(t *testing.T) {
+		runner := &Runner{Interpreters: map[string][]string{".py": {"python3", "-u"}}}
+		assert.Equal(t, []string{"python3", "-u", "test.py"}, runner.Command("test.py"))
+		pass := filepath.Join(dir, "pass.sh")
+		assert.Equal(t, []string{"/bin/sh", pass}, runner.Command(pass))
+		assert.Equal(t, []string{"./test"}, runner.Command("test"))
+	})
+	t.Run("RunsTestProgramsInOrder", func(t *testing.T) {
+		runner := &Runner{
+			Jobs:         3,
+			Timeout:      time.Second,
+			Interpreters: map[string][]string{"": {"/bin/sh"}},
+		}
+		paths := []string{
+			filepath.Join(dir, "pass.sh"),
+			filepath.Join(dir, "fail.sh"),
+			filepath.Join(dir, "exit.sh"),
+			filepath.Join(dir, "slow.sh"),
+			filepath.Join(dir, "noshebang"),
+			filepath.Join(dir, "missing.sh"),
+		}
+		results := runner.Run(context.Background(), paths)
+		assert.Len(t, results, 6)
+		for i, result := range results {
+			assert.Equal(t, paths[i], result.Path)
+			assert.NotNil(t, result.Results)
+		}
+		assert.True(t, results[0].IsPassing())
+		assert.Equal(t, 2, results[0].Results.PassedTests)
+		assert.Equal(t, 0, results[0].ExitCode)
+		assert.False(t, results[1].IsPassing())
+		assert.Equal(t, []byte("oops\n"), results[1].Stderr)
+		assert.False(t, results[2].IsPassing())
+		assert.True(t, results[2].Results.IsPassing())
+		assert.Equal(t, 3, results[2].ExitCode)
+		assert.True(t, results[3].TimedOut)
+		assert.False(t, results[3].IsPassing())
+		assert.True(t, results[4].IsPassing())
+		assert.Error(t, results[5].Err)
+		assert.False(t, results[5].IsPassing())
+	})
+	t.Run("ReportsProgress", func(t *testing.T) {
+		var mutex sync.Mutex
+		var progress []Progress
+		runner := &Runner{
+			Jobs: 2,
+			Progress: func(p Progress) {
+				mutex.Lock()
+				defer mutex.Unlock()
+				progress = append(progress, p)
+			},
+		}
+		paths := []string{filepath.Join(dir, "pass.sh"), filepath.Join(dir, "fail.sh")}
+		runner.Run(context.Background(), paths)
+		started, tests, finished := 0, 0, 0
+		for _, p := range progress {
+			assert.Equal(t, paths[p.Index], p.Path)
+			switch {
+			case p.Started:
+				started++
+			case p.Event != nil:
+				if p.Event.Type == tap13.TestEvent {
+					tests++
+				}
+			case p.Result != nil:
+				finished++
+				assert.Equal(t, p.Path, p.Result.Path)
+			}
+		}
+		assert.Equal(t, 2, started)
+		assert.Equal(t, 3, tests)
+		assert.Equal(t, 2, finished)
+	})
+	t.Run("FailFastStopsOtherTestPrograms", func(t *testing.T) {
+		runner := &Runner{Jobs: 2, FailFast: true}
+		paths := []string{
+			filepath.Join(dir, "sleeper.sh"),
+			filepath.Join(dir, "bail.sh"),
+			filepath.Join(dir, "pass.sh"),
+		}
+		start := time.Now()
+		results := runner.Run(context.Background(), paths)
+		assert.True(t, time.Since(start) < 5*time.Second)
+		assert.True(t, results[0].Canceled)
+		assert.False(t, results[1].Canceled)
+		assert.True(t, results[1].Results.BailOut)
+		assert.True(t, results[2].Canceled)
+		assert.False(t, results[2].IsPassing())
+	})
+}
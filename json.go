@@ -0,0 +1,251 @@
+package tap13
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// JSONSchemaVersion is the version of the JSON representation of Results and Test written by
+// MarshalJSON. It is incremented whenever the representation changes in a way which older readers
+// would misinterpret. UnmarshalJSON accepts any version up to and including this one.
+const JSONSchemaVersion = 1
+
+// jsonResults is the JSON representation of Results.
+type jsonResults struct {
+	SchemaVersion            int          `json:"schema_version,omitempty"`
+	Name                     string       `json:"name,omitempty"`
+	Status                   string       `json:"status"`
+	TapVersion               int          `json:"tap_version"`
+	FoundTapData             bool         `json:"found_tap_data"`
+	ExpectedTests            int          `json:"expected_tests"`
+	TotalTests               int          `json:"total_tests"`
+	PassedTests              int          `json:"passed_tests"`
+	FailedTests              int          `json:"failed_tests"`
+	SkippedTests             int          `json:"skipped_tests"`
+	TodoTests                int          `json:"todo_tests"`
+	ExtraTests               int          `json:"extra_tests"`
+	UnexpectedlyPassingTodos int          `json:"unexpectedly_passing_todos"`
+	PlanSkipped              bool         `json:"plan_skipped,omitempty"`
+	PlanSkipReason           string       `json:"plan_skip_reason,omitempty"`
+	BailOut                  bool         `json:"bail_out,omitempty"`
+	BailOutReason            string       `json:"bail_out_reason,omitempty"`
+	Explanation              []string     `json:"explanation,omitempty"`
+	Tests                    []Test       `json:"tests,omitempty"`
+	Problems                 []ParseError `json:"problems,omitempty"`
+	Pragmas                  []Pragma     `json:"pragmas,omitempty"`
+	MissingTestNumbers       []int        `json:"missing_test_numbers,omitempty"`
+	DuplicateTestNumbers     []int        `json:"duplicate_test_numbers,omitempty"`
+	OutOfOrderTests          []int        `json:"out_of_order_tests,omitempty"`
+	VersionLine              int          `json:"version_line,omitempty"`
+	PlanLine                 int          `json:"plan_line,omitempty"`
+	BailOutLine              int          `json:"bail_out_line,omitempty"`
+	Lines                    []string     `json:"lines,omitempty"`
+}
+
+// jsonTest is the JSON representation of Test.
+type jsonTest struct {
+	Number      int                    `json:"number"`
+	Status      string                 `json:"status"`
+	Ok          bool                   `json:"ok"`
+	Description string                 `json:"description,omitempty"`
+	Directive   string                 `json:"directive,omitempty"`
+	Diagnostics []string               `json:"diagnostics,omitempty"`
+	YAML        map[string]interface{} `json:"yaml,omitempty"`
+	YAMLText    string                 `json:"yaml_text,omitempty"`
+	Subtests    *jsonResults           `json:"subtests,omitempty"`
+	Missing     bool                   `json:"missing,omitempty"`
+	Extra       bool                   `json:"extra,omitempty"`
+	StartLine   int                    `json:"start_line,omitempty"`
+	EndLine     int                    `json:"end_line,omitempty"`
+	Raw         string                 `json:"raw,omitempty"`
+}
+
+// MarshalJSON encodes the results as a JSON object. The object contains a "schema_version" key
+// (see JSONSchemaVersion), a "status" key ("pass" or "fail", according to IsPassing), and a
+// snake_case key for each field of Results which is set; for example, "expected_tests",
+// "bail_out_reason" and "tests". The "lines" key is only present if the Lines field is not nil, so
+// the raw input can be left out by setting Lines to nil before encoding. The results of subtests
+// are encoded without the "schema_version" and "lines" keys, since they are part of the enclosing
+// results. The receiver is a value, so that the same encoding is used for a Results value as for a
+// pointer to one.
+func (r Results) MarshalJSON() ([]byte, error) {
+	encoded := r.toJSON()
+	encoded.SchemaVersion = JSONSchemaVersion
+	encoded.Lines = r.Lines
+	return json.Marshal(encoded)
+}
+
+// toJSON returns the JSON representation of the results, without the schema version or lines.
+func (r *Results) toJSON() *jsonResults {
+	status := "fail"
+	if r.IsPassing() {
+		status = "pass"
+	}
+	return &jsonResults{
+		Name:                     r.Name,
+		Status:                   status,
+		TapVersion:               r.TapVersion,
+		FoundTapData:             r.FoundTapData,
+		ExpectedTests:            r.ExpectedTests,
+		TotalTests:               r.TotalTests,
+		PassedTests:              r.PassedTests,
+		FailedTests:              r.FailedTests,
+		SkippedTests:             r.SkippedTests,
+		TodoTests:                r.TodoTests,
+		ExtraTests:               r.ExtraTests,
+		UnexpectedlyPassingTodos: r.UnexpectedlyPassingTodos,
+		PlanSkipped:              r.PlanSkipped,
+		PlanSkipReason:           r.PlanSkipReason,
+		BailOut:                  r.BailOut,
+		BailOutReason:            r.BailOutReason,
+		Explanation:              r.Explanation,
+		Tests:                    r.Tests,
+		Problems:                 r.Problems,
+		Pragmas:                  r.Pragmas,
+		MissingTestNumbers:       r.MissingTestNumbers,
+		DuplicateTestNumbers:     r.DuplicateTestNumbers,
+		OutOfOrderTests:          r.OutOfOrderTests,
+		VersionLine:              r.VersionLine,
+		PlanLine:                 r.PlanLine,
+		BailOutLine:              r.BailOutLine,
+	}
+}
+
+// UnmarshalJSON decodes results encoded by MarshalJSON. The "status" key is ignored, since it is
+// derived from the other fields. An error is returned if the schema version is not supported.
+func (r *Results) UnmarshalJSON(data []byte) error {
+	var decoded jsonResults
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	if decoded.SchemaVersion < 1 || decoded.SchemaVersion > JSONSchemaVersion {
+		return fmt.Errorf("tap13: unsupported JSON schema version %d", decoded.SchemaVersion)
+	}
+	*r = *decoded.toResults()
+	return nil
+}
+
+// toResults returns the results represented by the decoded JSON object.
+func (decoded *jsonResults) toResults() *Results {
+	return &Results{
+		Name:                     decoded.Name,
+		TapVersion:               decoded.TapVersion,
+		FoundTapData:             decoded.FoundTapData,
+		ExpectedTests:            decoded.ExpectedTests,
+		TotalTests:               decoded.TotalTests,
+		PassedTests:              decoded.PassedTests,
+		FailedTests:              decoded.FailedTests,
+		SkippedTests:             decoded.SkippedTests,
+		TodoTests:                decoded.TodoTests,
+		ExtraTests:               decoded.ExtraTests,
+		UnexpectedlyPassingTodos: decoded.UnexpectedlyPassingTodos,
+		PlanSkipped:              decoded.PlanSkipped,
+		PlanSkipReason:           decoded.PlanSkipReason,
+		BailOut:                  decoded.BailOut,
+		BailOutReason:            decoded.BailOutReason,
+		Explanation:              decoded.Explanation,
+		Tests:                    decoded.Tests,
+		Problems:                 decoded.Problems,
+		Pragmas:                  decoded.Pragmas,
+		MissingTestNumbers:       decoded.MissingTestNumbers,
+		DuplicateTestNumbers:     decoded.DuplicateTestNumbers,
+		OutOfOrderTests:          decoded.OutOfOrderTests,
+		VersionLine:              decoded.VersionLine,
+		PlanLine:                 decoded.PlanLine,
+		BailOutLine:              decoded.BailOutLine,
+		Lines:                    decoded.Lines,
+	}
+}
+
+// MarshalJSON encodes the test as a JSON object. The outcome of the test is encoded as a single
+// "status" key (the name of its Status, such as "pass" or "todo-fail"), rather than as separate
+// flags, along with the "ok" key (the Ok field). The YAML diagnostic block is encoded both as an
+// object (under the "yaml" key, if it could be decoded), and as the original text (under the
+// "yaml_text" key).
+func (t Test) MarshalJSON() ([]byte, error) {
+	decoded := jsonTest{
+		Number:      t.TestNumber,
+		Status:      t.Status().String(),
+		Ok:          t.Ok,
+		Description: t.Description,
+		Directive:   t.DirectiveText,
+		Diagnostics: t.Diagnostics,
+		YAMLText:    string(t.YamlBytes),
+		Missing:     t.Missing,
+		Extra:       t.Extra,
+		StartLine:   t.StartLine,
+		EndLine:     t.EndLine,
+		Raw:         t.Raw,
+	}
+	if t.Subtests != nil {
+		decoded.Subtests = t.Subtests.toJSON()
+	}
+	if yamlData, err := t.YAML(); err == nil {
+		decoded.YAML = jsonCompatible(yamlData).(map[string]interface{})
+	}
+	return json.Marshal(&decoded)
+}
+
+// UnmarshalJSON decodes a test encoded by MarshalJSON. The YAML diagnostic block is restored from
+// its original text; the "yaml" key is ignored.
+func (t *Test) UnmarshalJSON(data []byte) error {
+	var decoded jsonTest
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	*t = Test{
+		TestNumber:    decoded.Number,
+		Ok:            decoded.Ok,
+		Description:   decoded.Description,
+		DirectiveText: decoded.Directive,
+		Diagnostics:   decoded.Diagnostics,
+		Missing:       decoded.Missing,
+		Extra:         decoded.Extra,
+		StartLine:     decoded.StartLine,
+		EndLine:       decoded.EndLine,
+		Raw:           decoded.Raw,
+	}
+	if decoded.Subtests != nil {
+		t.Subtests = decoded.Subtests.toResults()
+	}
+	if decoded.YAMLText != "" {
+		t.YamlBytes = []byte(decoded.YAMLText)
+	}
+	switch decoded.Status {
+	case "pass":
+		t.Passed = true
+	case "fail":
+		t.Failed = true
+	case "skip":
+		t.Skipped = true
+	case "todo-fail", "todo-pass":
+		t.Todo = true
+	default:
+		return fmt.Errorf("tap13: unknown test status %q", decoded.Status)
+	}
+	return nil
+}
+
+// jsonCompatible converts any mappings with non-string keys in the specified decoded YAML value
+// (which encoding/json cannot encode) to mappings with string keys.
+func jsonCompatible(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			value[key] = jsonCompatible(item)
+		}
+		return value
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			converted[fmt.Sprint(key)] = jsonCompatible(item)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range value {
+			value[i] = jsonCompatible(item)
+		}
+		return value
+	}
+	return value
+}
@@ -0,0 +1,101 @@
+package tap13
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	util "github.com/mpontillo/tap13/internal"
+)
+
+func TestJSON(t *testing.T) {
+	t.Run("EncodesDocumentedSchema", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+1..2
+ok 1 - first
+not ok 2 - second # TODO later
+  ---
+  message: failed
+  1: numeric key
+  ...`,
+			"\n")
+		result := Parse(input)
+		result.Lines = nil
+		encoded, err := json.Marshal(result)
+		assert.NoError(t, err)
+		var decoded map[string]interface{}
+		assert.NoError(t, json.Unmarshal(encoded, &decoded))
+		assert.Equal(t, float64(JSONSchemaVersion), decoded["schema_version"])
+		assert.Equal(t, "pass", decoded["status"])
+		assert.Equal(t, float64(2), decoded["expected_tests"])
+		assert.NotContains(t, decoded, "lines")
+		tests := decoded["tests"].([]interface{})
+		assert.Equal(t, "pass", tests[0].(map[string]interface{})["status"])
+		second := tests[1].(map[string]interface{})
+		assert.Equal(t, "todo-fail", second["status"])
+		assert.Equal(t, false, second["ok"])
+		assert.Equal(t, "TODO later", second["directive"])
+		assert.Equal(t, map[string]interface{}{"message": "failed", "1": "numeric key"},
+			second["yaml"])
+		assert.Equal(t, "  message: failed\n  1: numeric key\n", second["yaml_text"])
+	})
+	t.Run("EncodesResultsValues", func(t *testing.T) {
+		result := Parse([]string{"TAP version 13", "1..1", "ok 1"})
+		expected, err := json.Marshal(result)
+		assert.NoError(t, err)
+		encoded, err := json.Marshal(*result)
+		assert.NoError(t, err)
+		assert.JSONEq(t, string(expected), string(encoded))
+		encoded, err = json.Marshal(struct{ Results Results }{*result})
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"Results": `+string(expected)+`}`, string(encoded))
+		assert.Contains(t, string(encoded), `"schema_version":1`)
+	})
+	t.Run("RoundTripsTestData", func(t *testing.T) {
+		files, err := filepath.Glob("testdata/*.tap1?")
+		assert.NoError(t, err)
+		assert.NotEmpty(t, files)
+		for _, file := range files {
+			result := ParseWithOptions(util.ReadFile(file), ParseOptions{Strict: true})
+			encoded, err := json.Marshal(result)
+			assert.NoError(t, err, file)
+			decoded := &Results{}
+			assert.NoError(t, json.Unmarshal(encoded, decoded), file)
+			assert.Equal(t, result, decoded, file)
+		}
+	})
+	t.Run("RoundTripsSubtestsAndProblems", func(t *testing.T) {
+		input := strings.Split(`TAP version 14
+pragma +strict
+# Subtest: outer
+    ok 1 - inner
+ok 1 - outer
+ok 3
+Bail out! done`,
+			"\n")
+		result := ParseWithOptions(input, ParseOptions{MissingTestPlaceholders: true})
+		encoded, err := json.Marshal(result)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, strings.Count(string(encoded), `"schema_version"`))
+		assert.Equal(t, 1, strings.Count(string(encoded), `"lines"`))
+		decoded := &Results{}
+		assert.NoError(t, json.Unmarshal(encoded, decoded))
+		// The lines of a subtest are part of the enclosing lines.
+		result.Tests[0].Subtests.Lines = nil
+		assert.Equal(t, result, decoded)
+	})
+	t.Run("RejectsUnknownTestStatus", func(t *testing.T) {
+		decoded := &Results{}
+		assert.Error(t, json.Unmarshal([]byte(`{"schema_version": 1, "tests": [
+			{"number": 1, "status": "todo"}
+		]}`), decoded))
+	})
+	t.Run("RejectsUnsupportedSchemaVersion", func(t *testing.T) {
+		decoded := &Results{}
+		assert.Error(t, json.Unmarshal([]byte(`{"schema_version": 999}`), decoded))
+		assert.Error(t, json.Unmarshal([]byte(`{"tests": []}`), decoded))
+	})
+}
@@ -0,0 +1,219 @@
+/*
+Package junit converts TAP test results to the JUnit XML format understood by most continuous
+integration systems (such as Jenkins and GitLab).
+
+Each Test in the Results is written as a <testcase>. Failed tests include a <failure> element
+whose body contains the test diagnostics and its YAML diagnostic block. Skipped and TODO tests
+include a <skipped> element. A bail out is written as an additional test case with an <error>
+element, and each test which was planned but not run is written as an additional failing test
+case. If too many tests were missing to list them all, one more failing test case says so.
+
+Diagnostics are escaped as character data; characters which are not allowed in XML (such as the
+escape character of ANSI colour codes) are replaced with U+FFFD.
+*/
+package junit
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/mpontillo/tap13"
+)
+
+// Suite associates a name (such as the name of the file the TAP output was read from) with the
+// results of a test run. Each Suite is written as a <testsuite> element.
+type Suite struct {
+	Name    string
+	Results *tap13.Results
+}
+
+type testSuites struct {
+	XMLName  xml.Name    `xml:"testsuites"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Errors   int         `xml:"errors,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Suites   []testSuite `xml:"testsuite"`
+}
+
+type testSuite struct {
+	Name      string     `xml:"name,attr"`
+	Tests     int        `xml:"tests,attr"`
+	Failures  int        `xml:"failures,attr"`
+	Errors    int        `xml:"errors,attr"`
+	Skipped   int        `xml:"skipped,attr"`
+	TestCases []testCase `xml:"testcase"`
+}
+
+type testCase struct {
+	Name      string   `xml:"name,attr"`
+	ClassName string   `xml:"classname,attr"`
+	Failure   *message `xml:"failure"`
+	Error     *message `xml:"error"`
+	Skipped   *message `xml:"skipped"`
+}
+
+type message struct {
+	Message string `xml:"message,attr,omitempty"`
+	Body    string `xml:",chardata"`
+}
+
+// Write writes the specified suites to w as a JUnit XML document.
+func Write(w io.Writer, suites ...Suite) error {
+	document := testSuites{}
+	for _, suite := range suites {
+		s := convertSuite(suite)
+		document.Tests += s.Tests
+		document.Failures += s.Failures
+		document.Errors += s.Errors
+		document.Skipped += s.Skipped
+		document.Suites = append(document.Suites, s)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(document); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func convertSuite(suite Suite) testSuite {
+	results := suite.Results
+	s := testSuite{Name: suite.Name}
+	for i := range results.Tests {
+		if results.Tests[i].Extra {
+			// Tests beyond the plan are not part of the run.
+			continue
+		}
+		s.TestCases = append(s.TestCases, convertTest(suite.Name, i, &results.Tests[i]))
+	}
+	if !results.FoundTapData {
+		s.TestCases = append(s.TestCases, testCase{
+			Name:      "TAP output",
+			ClassName: suite.Name,
+			Error:     &message{Message: "no TAP output found"},
+		})
+	}
+	placeholders := make(map[int]bool)
+	for _, test := range results.Tests {
+		if test.Missing {
+			// The missing test was already converted from its placeholder.
+			placeholders[test.TestNumber] = true
+		}
+	}
+	if results.PlanSkipped {
+		s.TestCases = append(s.TestCases, testCase{
+			Name:      "TAP output",
+			ClassName: suite.Name,
+			Skipped:   &message{Message: results.PlanSkipReason},
+		})
+	}
+	for _, number := range results.MissingTestNumbers {
+		if number > results.ExpectedTests || placeholders[number] {
+			continue
+		}
+		s.TestCases = append(s.TestCases, testCase{
+			Name:      fmt.Sprintf("test %d", number),
+			ClassName: suite.Name,
+			Failure:   &message{Message: "planned test was not run"},
+		})
+	}
+	if hasProblem(results, tap13.TooManyMissingTests) {
+		s.TestCases = append(s.TestCases, testCase{
+			Name:      "Missing tests",
+			ClassName: suite.Name,
+			Failure:   &message{Message: "too many planned tests were not run to list them all"},
+		})
+	}
+	if results.BailOut {
+		reason := results.BailOutReason
+		if reason == "" {
+			reason = "(no reason given)"
+		}
+		s.TestCases = append(s.TestCases, testCase{
+			Name:      "Bail out!",
+			ClassName: suite.Name,
+			Error:     &message{Message: reason},
+		})
+	}
+	for _, c := range s.TestCases {
+		s.Tests++
+		if c.Failure != nil {
+			s.Failures++
+		}
+		if c.Error != nil {
+			s.Errors++
+		}
+		if c.Skipped != nil {
+			s.Skipped++
+		}
+	}
+	return s
+}
+
+func convertTest(className string, index int, test *tap13.Test) testCase {
+	c := testCase{Name: strings.TrimPrefix(test.Description, "- "), ClassName: className}
+	if c.Name == "" {
+		number := test.TestNumber
+		if number <= 0 {
+			number = index + 1
+		}
+		c.Name = fmt.Sprintf("test %d", number)
+	}
+	switch {
+	case test.Missing:
+		c.Failure = &message{Message: "planned test was not run"}
+	case test.Skipped || test.Todo:
+		c.Skipped = &message{Message: test.DirectiveText}
+	case test.Failed:
+		c.Failure = &message{Message: "not ok", Body: failureBody(test)}
+		if diagnostic, err := test.Diagnostic(); err == nil && diagnostic != nil {
+			if diagnostic.Message != "" {
+				c.Failure.Message = diagnostic.Message
+			}
+		}
+	}
+	return c
+}
+
+func hasProblem(results *tap13.Results, code tap13.ProblemCode) bool {
+	for _, problem := range results.Problems {
+		if problem.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// failureBody returns the diagnostics for the specified test, followed by its YAML diagnostic
+// block (normalized by decoding and re-encoding it, if possible).
+func failureBody(test *tap13.Test) string {
+	var body strings.Builder
+	for _, line := range test.Diagnostics {
+		body.WriteString(line)
+		body.WriteString("\n")
+	}
+	if len(test.YamlBytes) == 0 {
+		return body.String()
+	}
+	decoded, err := test.YAML()
+	if err == nil {
+		var encoded []byte
+		encoded, err = yaml.Marshal(decoded)
+		if err == nil {
+			body.Write(encoded)
+		}
+	}
+	if err != nil {
+		body.Write(test.YamlBytes)
+	}
+	return body.String()
+}
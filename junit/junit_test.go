@@ -0,0 +1,120 @@
+package junit
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mpontillo/tap13"
+)
+
+func writeAndDecode(t *testing.T, suites ...Suite) testSuites {
+	var buffer bytes.Buffer
+	assert.NoError(t, Write(&buffer, suites...))
+	assert.True(t, strings.HasPrefix(buffer.String(), xml.Header))
+	var document testSuites
+	assert.NoError(t, xml.Unmarshal(buffer.Bytes(), &document))
+	return document
+}
+
+func TestWrite(t *testing.T) {
+	t.Run("MapsEachTestToTestCase", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+1..5
+ok 1 - passes
+not ok 2 - fails
+# diagnostic line
+  ---
+  message: values differ
+  got: 1
+  expected: 2
+  ...
+ok 3 # SKIP not today
+not ok 4 # TODO later
+ok`,
+			"\n")
+		document := writeAndDecode(t, Suite{Name: "example.t", Results: tap13.Parse(input)})
+		assert.Equal(t, 5, document.Tests)
+		assert.Equal(t, 1, document.Failures)
+		assert.Equal(t, 0, document.Errors)
+		assert.Equal(t, 2, document.Skipped)
+		suite := document.Suites[0]
+		assert.Equal(t, "example.t", suite.Name)
+		assert.Len(t, suite.TestCases, 5)
+		assert.Equal(t, "passes", suite.TestCases[0].Name)
+		assert.Equal(t, "example.t", suite.TestCases[0].ClassName)
+		assert.Nil(t, suite.TestCases[0].Failure)
+		failure := suite.TestCases[1].Failure
+		assert.Equal(t, "values differ", failure.Message)
+		assert.Equal(t, "diagnostic line\nexpected: 2\ngot: 1\nmessage: values differ\n",
+			failure.Body)
+		assert.Equal(t, "SKIP not today", suite.TestCases[2].Skipped.Message)
+		assert.Equal(t, "TODO later", suite.TestCases[3].Skipped.Message)
+		assert.Equal(t, "test 5", suite.TestCases[4].Name)
+	})
+	t.Run("AddsMissingTestsAndBailOut", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+1..3
+ok 1
+Bail out!`,
+			"\n")
+		document := writeAndDecode(t, Suite{Name: "bail.t", Results: tap13.Parse(input)})
+		suite := document.Suites[0]
+		assert.Equal(t, 4, suite.Tests)
+		assert.Equal(t, 2, suite.Failures)
+		assert.Equal(t, 1, suite.Errors)
+		assert.Equal(t, "test 2", suite.TestCases[1].Name)
+		assert.Equal(t, "planned test was not run", suite.TestCases[1].Failure.Message)
+		assert.Equal(t, "Bail out!", suite.TestCases[3].Name)
+		assert.Equal(t, "(no reason given)", suite.TestCases[3].Error.Message)
+	})
+	t.Run("ConvertsMissingTestPlaceholders", func(t *testing.T) {
+		input := []string{"TAP version 13", "1..3", "ok 1", "ok 3"}
+		results := tap13.ParseWithOptions(input, tap13.ParseOptions{MissingTestPlaceholders: true})
+		document := writeAndDecode(t, Suite{Name: "gap.t", Results: results})
+		suite := document.Suites[0]
+		assert.Equal(t, 3, suite.Tests)
+		assert.Equal(t, 1, suite.Failures)
+		assert.Equal(t, "test 2", suite.TestCases[2].Name)
+		assert.Equal(t, "planned test was not run", suite.TestCases[2].Failure.Message)
+	})
+	t.Run("UsesMissingTestNumbers", func(t *testing.T) {
+		input := []string{"TAP version 13", "1..3", "ok 1", "ok 3"}
+		document := writeAndDecode(t, Suite{Name: "gap.t", Results: tap13.Parse(input)})
+		suite := document.Suites[0]
+		assert.Equal(t, 3, suite.Tests)
+		assert.Equal(t, "test 2", suite.TestCases[2].Name)
+		input = []string{"TAP version 13", "1..50000000", "ok 1"}
+		document = writeAndDecode(t, Suite{Name: "huge.t", Results: tap13.Parse(input)})
+		suite = document.Suites[0]
+		assert.Equal(t, 1003, suite.Tests)
+		assert.Equal(t, 1002, suite.Failures)
+		assert.Equal(t, "test 1002", suite.TestCases[1001].Name)
+		assert.Equal(t, "Missing tests", suite.TestCases[1002].Name)
+	})
+	t.Run("ReportsSkippedPlanAsSkipped", func(t *testing.T) {
+		input := []string{"TAP version 13", "1..0 # skip no database"}
+		document := writeAndDecode(t, Suite{Name: "db.t", Results: tap13.Parse(input)})
+		suite := document.Suites[0]
+		assert.Equal(t, 1, suite.Tests)
+		assert.Equal(t, 1, suite.Skipped)
+		assert.Equal(t, "no database", suite.TestCases[0].Skipped.Message)
+	})
+	t.Run("ReplacesCharactersNotAllowedInXML", func(t *testing.T) {
+		input := []string{"TAP version 13", "1..1", "not ok 1", "# \x1b[31mred\x1b[0m"}
+		document := writeAndDecode(t, Suite{Name: "color.t", Results: tap13.Parse(input)})
+		assert.Equal(t, "\uFFFD[31mred\uFFFD[0m\n", document.Suites[0].TestCases[0].Failure.Body)
+	})
+	t.Run("ReportsMissingTapAsError", func(t *testing.T) {
+		document := writeAndDecode(t,
+			Suite{Name: "a.t", Results: tap13.Parse([]string{"TAP version 13", "ok"})},
+			Suite{Name: "b.t", Results: tap13.Parse([]string{"no TAP here"})})
+		assert.Len(t, document.Suites, 2)
+		assert.Equal(t, 2, document.Tests)
+		assert.Equal(t, 1, document.Errors)
+		assert.Equal(t, "no TAP output found", document.Suites[1].TestCases[0].Error.Message)
+	})
+}
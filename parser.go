@@ -5,21 +5,46 @@ The full protocol specification can be found at the following URL:
 
 https://testanything.org/tap-version-13-specification.html
 
+Subtests, as specified by TAP version 14 (and produced by Test::More and node-tap), are also
+supported. A subtest is a TAP stream indented by four spaces, optionally introduced by a
+"# Subtest: name" line, and followed by a test line which summarizes its result.
+
 */
 package tap13
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"regexp"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 // Test encapsulates the result of a specific test, including a description and diagnostics (if
-// supplied). The TestNumber field is undefined if the TAP output does not include test numbers.
-// Diagnostics are supplied with trimmed whitespace, and blank lines removed.
+// supplied). The TestNumber field is undefined if the TAP output does not include test numbers. The
+// "\#" and "\\" escapes in the description and directive text are interpreted. Diagnostics are
+// supplied with trimmed whitespace, and blank lines removed (unless
+// ParseOptions.KeepBlankDiagnostics is set). If the test line summarized the result of a subtest,
+// the Subtests field contains the results of the subtest. The Missing field is set for a
+// placeholder test, standing in for a planned test which was not found. The Extra field is set for
+// a test found after all the tests in the plan.
+//
+// At most one of the Passed, Failed, Skipped and Todo fields is set, according to the directive
+// (if any) and the result of the test. The Ok field contains the result as written on the test line
+// ("ok" or "not ok"), regardless of any directive; the Status method combines the two. The
+// DirectiveText field contains the whole text of the directive, including its keyword (such as
+// "TODO working on this one"); the Directive method splits it into its kind and reason.
+//
+// The StartLine field contains the 1-based line number of the test line, and the EndLine field
+// contains the line number of the last line belonging to the test (including any diagnostics and
+// YAML block which follow it), so that Results.Lines[StartLine-1:EndLine] contains the lines which
+// describe the test. The Raw field contains the text of the test line itself (without the
+// indentation of a subtest).
 type Test struct {
 	TestNumber    int
+	Ok            bool
 	Passed        bool
 	Failed        bool
 	Skipped       bool
@@ -28,13 +53,42 @@ type Test struct {
 	DirectiveText string
 	Diagnostics   []string
 	YamlBytes     []byte
+	Subtests      *Results
+	Missing       bool
+	Extra         bool
+	StartLine     int
+	EndLine       int
+	Raw           string
 }
 
 // Results encapsulates the result of the entire test run. If a plan was given in the input TAP, the
 // ExpectedTests will be greater than or equal to zero. The input lines are preserved in the Lines
 // field. Any diagnostics given before the output of a test run is preserved in the Explanation.
 // The Tests field contains a Test struct for each test that was run, in the order that it appeared
-// in the TAP output.
+// in the TAP output. For the results of a subtest, the Name field contains the name given in the
+// "# Subtest" line which introduced it (if any). Any anomalies found while parsing the input are
+// listed in the Problems field, in the order they were found.
+//
+// If the plan declared that no tests would be run (such as "1..0 # SKIP reason"), the PlanSkipped
+// field is set, and the PlanSkipReason field contains the reason given (if any). Such a test run
+// is considered passing.
+//
+// Each pragma found is listed in the Pragmas field, in the order they were found. The "pragma
+// +strict" line enables strict parsing (see ParseOptions) for the remainder of the input, and
+// "pragma -strict" disables it.
+//
+// Tests without a test number are numbered by their position. The MissingTestNumbers field lists
+// each test number which was not found, up to the number of tests in the plan (or the highest test
+// number found, if there was no plan). The DuplicateTestNumbers field lists each test number found
+// more than once, and the OutOfOrderTests field lists each test number found after a higher one.
+// The ExtraTests field counts the tests found after all the tests in the plan; they are not
+// included in the other counts. The UnexpectedlyPassingTodos field counts the TODO tests which
+// passed (and so may no longer need to be marked TODO); they are also included in the TodoTests
+// count.
+//
+// The VersionLine, PlanLine and BailOutLine fields contain the 1-based line numbers of the version
+// line, the plan, and the last bail out (or zero, if there was none). Line numbers within a subtest
+// refer to the lines of the enclosing input.
 type Results struct {
 	ExpectedTests int
 	TotalTests    int
@@ -42,15 +96,35 @@ type Results struct {
 	FailedTests   int
 	SkippedTests  int
 	TodoTests     int
-	TapVersion    int
-	BailOut       bool
-	BailOutReason string
-	FoundTapData  bool
-	Tests         []Test
-	Lines         []string
-	Explanation   []string
+	ExtraTests    int
+
+	UnexpectedlyPassingTodos int
+
+	TapVersion     int
+	BailOut        bool
+	BailOutReason  string
+	PlanSkipped    bool
+	PlanSkipReason string
+	FoundTapData   bool
+	Tests          []Test
+	Lines          []string
+	Explanation    []string
+	Problems       []ParseError
+	Name           string
+	Pragmas        []Pragma
+
+	MissingTestNumbers   []int
+	DuplicateTestNumbers []int
+	OutOfOrderTests      []int
+
+	VersionLine int
+	PlanLine    int
+	BailOutLine int
 }
 
+// ignoredTest is the value of Parser.currentTest while an extra test is being ignored.
+const ignoredTest = -2
+
 const (
 	findVersionString = iota
 	storeTestMetadata
@@ -64,7 +138,13 @@ func (r *Results) String() string {
 	} else {
 		result += " Overall result: FAIL\n"
 	}
-	if r.TotalTests == 0 || r.PassedTests != r.TotalTests {
+	if r.PlanSkipped {
+		reason := r.PlanSkipReason
+		if reason == "" {
+			reason = "(no reason given)"
+		}
+		result += fmt.Sprintf("   Plan skipped: %s\n", reason)
+	} else if r.TotalTests == 0 || r.PassedTests != r.TotalTests {
 		result += fmt.Sprintf("Total tests run: %d\n", r.TotalTests)
 	}
 	if r.ExpectedTests > 0 && r.ExpectedTests != r.TotalTests {
@@ -73,6 +153,9 @@ func (r *Results) String() string {
 	if r.ExpectedTests > 0 && r.TotalTests < r.ExpectedTests {
 		result += fmt.Sprintf("  Missing tests: %d\n", r.ExpectedTests-r.TotalTests)
 	}
+	if r.ExtraTests > 0 {
+		result += fmt.Sprintf("    Extra tests: %d\n", r.ExtraTests)
+	}
 	if r.PassedTests > 0 {
 		result += fmt.Sprintf("   Passed tests: %d\n", r.PassedTests)
 	}
@@ -85,6 +168,9 @@ func (r *Results) String() string {
 	if r.TodoTests > 0 {
 		result += fmt.Sprintf("     TODO tests: %d\n", r.TodoTests)
 	}
+	if r.UnexpectedlyPassingTodos > 0 {
+		result += fmt.Sprintf("    TODO passed: %d\n", r.UnexpectedlyPassingTodos)
+	}
 	if r.BailOut {
 		var reason string
 		if r.BailOutReason != "" {
@@ -97,7 +183,19 @@ func (r *Results) String() string {
 	return result
 }
 
+// UnexpectedlyPassingTodoTests returns the TODO tests which passed, in the order they were found.
+func (r *Results) UnexpectedlyPassingTodoTests() []Test {
+	var tests []Test
+	for _, test := range r.Tests {
+		if !test.Extra && test.Status() == StatusTodoPass {
+			tests = append(tests, test)
+		}
+	}
+	return tests
+}
+
 // IsPassing checks if the test results should be considered passing (true) or failing (false).
+// Test results with any problem of SeverityError, including a problem in a subtest, are failing.
 func (r *Results) IsPassing() bool {
 	if r.TapVersion < 0 {
 		// We didn't find a TAP header, so we can't really call this a success.
@@ -117,144 +215,735 @@ func (r *Results) IsPassing() bool {
 		// assume that the total number of tests is equal to the number of tests that were found.
 		testCount = r.TotalTests
 	}
+	if r.hasErrors() {
+		// The input violated the TAP specification while parsing strictly.
+		return false
+	}
 	return r.TodoTests+r.SkippedTests+r.PassedTests == testCount
 }
 
+// hasErrors checks if any problem with SeverityError was found in the results, or in the results
+// of any subtest.
+func (r *Results) hasErrors() bool {
+	for _, problem := range r.Problems {
+		if problem.Severity == SeverityError {
+			return true
+		}
+	}
+	for _, test := range r.Tests {
+		if test.Subtests != nil && test.Subtests.hasErrors() {
+			return true
+		}
+	}
+	return false
+}
+
 var versionLine = regexp.MustCompile(`^TAP version (\d+)`)
 var bailOutLine = regexp.MustCompile(`^Bail out!\s*(\S.*)?$`)
 var testLine = regexp.MustCompile(`^(not )?ok\b(.*)`)
-var optionalTestLine = regexp.MustCompile(`\s*(\d*)?\s*([^#]*)(#\s*((\w*)\s*.*)\s*)?`)
-var testPlanDeclaration = regexp.MustCompile(`^\d+\.\.(\d+)$`)
+var optionalTestLine = regexp.MustCompile(`\s*(\d*)?\s*((?:[^#\\]|\\.)*\\?)(#\s*((\w*)\s*.*)\s*)?`)
+var testPlanDeclaration = regexp.MustCompile(`^\d+\.\.(\d+)(\s*#\s*(.*?)\s*)?$`)
+var malformedTestPlan = regexp.MustCompile(`^\s*\d+\.\.`)
 var diagnostic = regexp.MustCompile(`\s*#(.*)$`)
 var yamlStart = regexp.MustCompile(`^\s*---$`)
+var yamlStop = regexp.MustCompile(`^\s*\.\.\.$`)
 
-// Parse interprets the specified lines as output lines from a program that generate TAP output,
-// and returns a corresponding Results structure containing the test results based on its
-// interpretation.
-func Parse(lines []string) *Results {
+// ParseOptions controls how TAP output is interpreted. The zero value gives the lenient behavior of
+// Parse, which tolerates common deviations from the specification.
+type ParseOptions struct {
+	// Strict causes each violation of the TAP specification to be recorded as a problem with
+	// SeverityError, which causes the test run to be considered failing. In addition to the
+	// problems recorded when parsing leniently, a strict parser reports a missing plan, a plan
+	// found between test lines, and test numbers which are duplicated or out of sequence. A
+	// "pragma +strict" or "pragma -strict" line changes this for the remainder of the input.
+	Strict bool
+	// MissingTestPlaceholders causes a failed Test, with the Missing field set, to be added to
+	// the end of the Tests for each test in the plan which was not found. Placeholder tests are
+	// not included in any of the test counts.
+	MissingTestPlaceholders bool
+	// ExtraTests determines what is done with tests found after all the tests in the plan.
+	ExtraTests ExtraTestPolicy
+	// Pragmas defines custom pragmas. Each time a pragma with one of the specified names is
+	// found, the corresponding hook function (if not nil) is called. Any other pragma, except for
+	// the built-in "strict" pragma, is recorded as an UnknownPragma problem.
+	Pragmas map[string]func(Pragma)
+	// FailPassingTodos causes a PassingTodo problem to be recorded as an error for each TODO
+	// test which passes, which causes the run to be considered failing, so that TODO directives
+	// are removed once they are no longer needed.
+	FailPassingTodos bool
+	// Versions lists the TAP versions which are accepted. A version line declaring any other
+	// version is recorded as an UnsupportedVersion problem, and ignored. If empty, any version is
+	// accepted.
+	Versions []int
+	// AllowMissingVersion causes the TAP data to begin at the first non-blank line if it is not
+	// preceded by a version line, as produced by TAP version 12 producers. The TapVersion of the
+	// results is then 12, as the specification implies.
+	AllowMissingVersion bool
+	// KeepBlankDiagnostics causes diagnostic lines with no text (such as a lone "#") to be kept
+	// as empty strings in the Diagnostics of a test (or the Explanation), rather than removed, so
+	// that the layout of multi-line diagnostics is preserved.
+	KeepBlankDiagnostics bool
+	// TrimYamlIndent causes the indentation of the "---" line of a YAML block to be removed from
+	// each line stored in YamlBytes, so that it contains the YAML document as if written alone.
+	TrimYamlIndent bool
+	// MaxLines limits the number of lines parsed. Any further lines are ignored, and a
+	// TooManyLines problem is recorded. If zero, there is no limit.
+	MaxLines int
+	// MaxLineLength limits the length of each line, in bytes. A longer line is truncated to this
+	// length (or less, so as not to split a UTF-8 encoded character), and a LineTooLong problem is
+	// recorded. If zero, there is no limit.
+	MaxLineLength int
+}
+
+// ExtraTestPolicy determines what is done with tests found after all the tests in the plan. In
+// every case, an ExtraTest problem is recorded, and the Results.ExtraTests count is incremented.
+type ExtraTestPolicy int
+
+const (
+	// RecordExtraTests records extra tests in Results.Tests, with the Extra field set. Extra tests
+	// are not included in any of the other test counts, and do not affect the outcome of the run.
+	RecordExtraTests ExtraTestPolicy = iota
+	// IgnoreExtraTests discards extra tests, along with any diagnostics or YAML which follow them.
+	IgnoreExtraTests
+	// FailExtraTests records extra tests as RecordExtraTests does, but records the ExtraTest
+	// problem as an error, which causes the run to be considered failing.
+	FailExtraTests
+)
+
+var unescaper = strings.NewReplacer(`\\`, `\`, `\#`, "#")
+
+// unescape interprets the "\\" and "\#" escapes in a description or directive. Any other
+// backslash is preserved.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		// Avoid the allocation made by the replacer when there is nothing to replace.
+		return s
+	}
+	return unescaper.Replace(s)
+}
+
+// truncate returns the longest prefix of the specified line which is no longer than the specified
+// number of bytes, and does not split a UTF-8 encoded character.
+func truncate(line string, length int) string {
+	for length > 0 && !utf8.RuneStart(line[length]) {
+		length--
+	}
+	return line[:length]
+}
+
+// Parser interprets TAP output incrementally, one line at a time. Lines may be supplied
+// individually using Feed, or read from an io.Reader using ReadFrom. As each TAP element is
+// interpreted, an Event is passed to the handler function (if one was given), which allows
+// consumers to report progress while a long-running test program is still producing output.
+type Parser struct {
+	handler       func(Event)
+	options       ParseOptions
+	results       *Results
+	state         int
+	currentTest   int
+	lineNumber    int
+	yamlStartLine int
+	yamlStartText string
+	// yamlIndent is the indentation of the YAML block in progress, if it is being trimmed.
+	yamlIndent    string
+	foundTestPlan bool
+	foundAllTests bool
+	// ignored collects the fields of an extra test which is being ignored.
+	ignored Test
+	// latePlanLine and latePlanText locate the plan, if it was found after the first test line.
+	latePlanLine int
+	latePlanText string
+	// testNumbers counts the occurrences of each test number found so far.
+	testNumbers       map[int]int
+	highestTestNumber int
+	// versionOptional is set if the TAP data may begin without a version line.
+	versionOptional bool
+	// truncated is set once MaxLines has been exceeded.
+	truncated bool
+	// subtest is the parser for the subtest in progress, if any.
+	subtest        *Parser
+	subtestLine    int
+	subtestText    string
+	subtestStarted bool
+	// subtestName is the name given by a "# Subtest" line which has not yet been followed by any
+	// indented lines, if headerPending is set.
+	subtestName   string
+	headerPending bool
+}
+
+// NewParser returns a Parser which will call the specified handler function for each Event, in
+// the order the events occur in the input. The handler may be nil, if only the final Results are
+// of interest.
+func NewParser(handler func(Event)) *Parser {
+	return NewParserWithOptions(ParseOptions{}, handler)
+}
+
+// NewParserWithOptions returns a Parser which interprets TAP output according to the specified
+// options, and calls the specified handler function (which may be nil) for each Event.
+func NewParserWithOptions(options ParseOptions, handler func(Event)) *Parser {
+	return &Parser{
+		handler:         handler,
+		options:         options,
+		testNumbers:     map[int]int{},
+		currentTest:     -1,
+		versionOptional: options.AllowMissingVersion,
+		results: &Results{
+			ExpectedTests: -1,
+			TapVersion:    -1,
+		},
+	}
+}
+
+// Results returns the results of the test run, based on the lines parsed so far. The returned
+// structure continues to be updated as more lines are parsed.
+func (p *Parser) Results() *Results {
+	return p.results
+}
+
+// Finish signals that no more input will be supplied, and returns the final Results.
+func (p *Parser) Finish() *Results {
+	p.flushSubtestHeader()
+	if !p.results.FoundTapData {
+		p.problem(0, SeverityError, MissingVersion, "")
+	}
+	if p.state == storeYaml {
+		p.violation(p.yamlStartLine, UnterminatedYaml, p.yamlStartText)
+	}
+	if p.options.Strict && p.results.FoundTapData && !p.foundTestPlan && !p.results.BailOut {
+		p.problem(0, SeverityError, MissingPlan, "")
+	}
+	if p.subtest != nil {
+		p.abandonSubtest()
+	}
+	p.findLateExtraTests()
+	p.findMissingTests()
+	return p.results
+}
+
+// ReadFrom parses each line read from the specified io.Reader until EOF or an error occurs. Line
+// endings ("\n" or "\r\n") are stripped before each line is parsed. The returned count is the
+// number of bytes read. ReadFrom does not call Finish, so that input from more than one reader may
+// be parsed.
+func (p *Parser) ReadFrom(r io.Reader) (int64, error) {
+	var count int64
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		count += int64(len(line))
+		if len(line) > 0 {
+			line = strings.TrimSuffix(line, "\n")
+			line = strings.TrimSuffix(line, "\r")
+			if err == nil || line != "" {
+				p.Feed(line)
+			}
+		}
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+	}
+}
+
+// problem records an anomaly found at the specified line number.
+func (p *Parser) problem(line int, severity Severity, code ProblemCode, text string) {
+	p.results.Problems = append(p.results.Problems, ParseError{
+		Line:     line,
+		Severity: severity,
+		Code:     code,
+		Text:     text,
+	})
+}
+
+// violation records input which does not conform to the TAP specification. It is tolerated with a
+// warning, unless parsing strictly.
+func (p *Parser) violation(line int, code ProblemCode, text string) {
+	severity := SeverityWarning
+	if p.options.Strict {
+		severity = SeverityError
+	}
+	p.problem(line, severity, code, text)
+}
+
+func (p *Parser) emit(event Event) {
+	p.emitAt(p.lineNumber, event)
+}
+
+// emitAt passes the specified event, which was found at the specified line number, to the handler.
+func (p *Parser) emitAt(line int, event Event) {
+	if p.handler == nil {
+		return
+	}
+	event.Line = line
+	p.handler(event)
+}
+
+// current returns a pointer to the test most recently found, or nil if no tests have been found.
+// The pointer is only valid until the next test is found.
+func (p *Parser) current() *Test {
+	if p.currentTest == ignoredTest {
+		return &p.ignored
+	}
+	if p.currentTest < 0 {
+		return nil
+	}
+	return &p.results.Tests[p.currentTest]
+}
+
+// currentCopy returns a pointer to a copy of the current test, suitable for use in an Event. As the
+// copy is made on the heap, callers should only make it if there is a handler to pass it to.
+func (p *Parser) currentCopy() *Test {
+	currentTest := p.current()
+	if currentTest == nil {
+		return nil
+	}
+	test := *currentTest
+	return &test
+}
+
+// Feed parses the specified line, which must not contain a line ending.
+func (p *Parser) Feed(line string) {
+	if p.options.MaxLines > 0 && p.lineNumber >= p.options.MaxLines {
+		if !p.truncated {
+			p.truncated = true
+			p.problem(p.lineNumber+1, SeverityError, TooManyLines, line)
+		}
+		return
+	}
+	p.lineNumber++
+	if p.options.MaxLineLength > 0 && len(line) > p.options.MaxLineLength {
+		line = truncate(line, p.options.MaxLineLength)
+		p.problem(p.lineNumber, SeverityWarning, LineTooLong, line)
+	}
+	p.results.Lines = append(p.results.Lines, line)
+	switch p.state {
+	case findVersionString:
+		p.findVersion(line)
+	case storeTestMetadata:
+		p.storeTestMetadata(line)
+	case storeYaml:
+		p.storeYaml(line)
+	}
+}
+
+func (p *Parser) findVersion(line string) {
+	results := p.results
+	versionMatch := versionLine.FindStringSubmatch(line)
+	if versionMatch != nil {
+		version, err := strconv.Atoi(versionMatch[1])
+		if err != nil {
+			// malformed test version line; keep looking
+			p.violation(p.lineNumber, MalformedVersion, line)
+			p.emit(Event{Type: UnknownEvent, Text: line})
+			return
+		}
+		if !p.acceptsVersion(version) {
+			p.violation(p.lineNumber, UnsupportedVersion, line)
+			p.emit(Event{Type: UnknownEvent, Text: line})
+			return
+		}
+		results.TapVersion = version
+		results.FoundTapData = true
+		results.VersionLine = p.lineNumber
+		p.state = storeTestMetadata
+		p.emit(Event{Type: VersionEvent, Text: line, Version: results.TapVersion})
+		return
+	}
+	if p.versionOptional && strings.TrimSpace(line) != "" {
+		// The version line may be omitted, so the first non-blank line starts the TAP data.
+		results.FoundTapData = true
+		if results.TapVersion < 0 {
+			// The version line was introduced by TAP version 13.
+			results.TapVersion = 12
+		}
+		p.state = storeTestMetadata
+		p.storeTestMetadata(line)
+		return
+	}
+	p.emit(Event{Type: UnknownEvent, Text: line})
+}
+
+// acceptsVersion checks if the specified TAP version is one of the accepted versions.
+func (p *Parser) acceptsVersion(version int) bool {
+	if len(p.options.Versions) == 0 {
+		return true
+	}
+	for _, accepted := range p.options.Versions {
+		if version == accepted {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Parser) storeTestMetadata(line string) {
 	var err error
-	var currentTest *Test
-	var yamlStop = regexp.MustCompile(`^\s*\.\.\.$`)
-	state := findVersionString
-	foundTestPlan := false
-	foundAllTests := false
-	results := &Results{
-		ExpectedTests: -1,
-		TapVersion:    -1,
-		Lines:         lines,
+	results := p.results
+	if p.storeSubtest(line) || p.storePragmas(line) {
+		return
 	}
-	for _, line := range lines {
-		switch state {
-		case findVersionString:
-			versionMatch := versionLine.FindStringSubmatch(line)
-			if versionMatch != nil {
-				results.TapVersion, err = strconv.Atoi(versionMatch[1])
-				if err != nil {
-					// malformed test version line; keep looking
-					continue
-				}
-				results.FoundTapData = true
-				state = storeTestMetadata
+	bailOutMatch := bailOutLine.FindStringSubmatch(line)
+	if bailOutMatch != nil {
+		results.BailOut = true
+		results.BailOutReason = bailOutMatch[1]
+		results.BailOutLine = p.lineNumber
+		p.emit(Event{Type: BailOutEvent, Text: line, BailOutReason: results.BailOutReason})
+		return
+	}
+	testPlan := testPlanDeclaration.FindStringSubmatch(line)
+	if testPlan != nil {
+		expectedTests, err := strconv.Atoi(testPlan[1])
+		// The only directive allowed on a plan is a SKIP, for a plan with no tests.
+		planDirective := ParseDirective(testPlan[3])
+		if err != nil || testPlan[2] != "" && (planDirective.Kind != DirectiveSkip || expectedTests != 0) {
+			// malformed test plan; keep looking
+			p.violation(p.lineNumber, MalformedPlan, line)
+			p.emit(Event{Type: UnknownEvent, Text: line})
+			return
+		}
+		if p.foundTestPlan {
+			// Only the first plan counts.
+			p.violation(p.lineNumber, DuplicatePlan, line)
+			p.emit(Event{Type: UnknownEvent, Text: line})
+			return
+		}
+		p.foundTestPlan = true
+		results.ExpectedTests = expectedTests
+		results.PlanLine = p.lineNumber
+		if expectedTests == 0 && len(results.Tests) == 0 {
+			// The whole test run was skipped, so any tests which follow are extra.
+			results.PlanSkipped = true
+			results.PlanSkipReason = planDirective.Reason
+			p.foundAllTests = true
+		}
+		if results.TotalTests >= expectedTests {
+			// Any tests which follow a late plan are extra.
+			p.foundAllTests = true
+		}
+		if len(results.Tests) > 0 {
+			// The plan must come either before or after all the test lines.
+			p.latePlanLine = p.lineNumber
+			p.latePlanText = line
+		}
+		p.emit(Event{Type: PlanEvent, Text: line, ExpectedTests: expectedTests})
+		return
+	}
+	if malformedTestPlan.MatchString(line) {
+		p.violation(p.lineNumber, MalformedPlan, line)
+		p.emit(Event{Type: UnknownEvent, Text: line})
+		return
+	}
+	testLineMatch := testLine.FindStringSubmatch(line)
+	if testLineMatch != nil {
+		// Start a new test; any diagnostics or YAML which follow will be attached to it.
+		results.Tests = append(results.Tests, Test{
+			StartLine: p.lineNumber,
+			EndLine:   p.lineNumber,
+			Raw:       line,
+		})
+		p.currentTest = len(results.Tests) - 1
+		currentTest := p.current()
+		if p.subtest != nil {
+			// A test line at this level terminates the subtest, and summarizes its result.
+			currentTest.Subtests = p.subtest.Finish()
+			p.subtest = nil
+		}
+		if p.foundAllTests {
+			// We've already found all the tests in the plan, so this one is extra.
+			p.reportExtraTest(p.lineNumber, line)
+			if p.options.ExtraTests == IgnoreExtraTests {
+				// Discard the test, along with any diagnostics or YAML which follow it.
+				results.Tests = results.Tests[:len(results.Tests)-1]
+				p.ignored = Test{}
+				p.currentTest = ignoredTest
+				p.emit(Event{Type: UnknownEvent, Text: line})
+				return
 			}
-		case storeTestMetadata:
-			bailOutMatch := bailOutLine.FindStringSubmatch(line)
-			if bailOutMatch != nil {
-				results.BailOut = true
-				results.BailOutReason = bailOutMatch[1]
-				break
+			currentTest.Extra = true
+		}
+		if p.latePlanLine > 0 && p.options.Strict {
+			p.problem(p.latePlanLine, SeverityError, MisplacedPlan, p.latePlanText)
+			p.latePlanLine = 0
+		}
+		optionalContentMatch := optionalTestLine.FindStringSubmatch(testLineMatch[2])
+		directive := optionalContentMatch[5]
+		directiveText := unescape(optionalContentMatch[4])
+		testNumString := optionalContentMatch[1]
+		if testNumString != "" {
+			currentTest.TestNumber, err = strconv.Atoi(testNumString)
+			if err != nil {
+				currentTest.TestNumber = -1
+				p.violation(p.lineNumber, MalformedTestNumber, line)
+			} else {
+				p.recordTestNumber(currentTest.TestNumber, line)
+			}
+		} else {
+			// A test without a number is numbered by its position.
+			p.recordTestNumber(len(results.Tests), line)
+		}
+		description := unescape(strings.TrimSpace(optionalContentMatch[2]))
+		currentTest.Description = description
+		isFailed := testLineMatch[1] == "not "
+		currentTest.Ok = !isFailed
+		// Process special cases first; they should not count toward the pass/fail count.
+		if directive != "" {
+			currentTest.DirectiveText = directiveText
+		}
+		if kind := currentTest.Directive().Kind; kind == DirectiveSkip {
+			currentTest.Skipped = true
+		} else if kind == DirectiveTodo {
+			currentTest.Todo = true
+		} else if isFailed {
+			currentTest.Failed = true
+		} else {
+			currentTest.Passed = true
+		}
+		if !currentTest.Extra {
+			p.count(currentTest)
+		}
+		if p.handler != nil {
+			p.emit(Event{Type: TestEvent, Text: line, Test: p.currentCopy()})
+		}
+	} else if yamlStart.MatchString(line) {
+		p.state = storeYaml
+		p.yamlStartLine = p.lineNumber
+		p.yamlStartText = line
+		if p.options.TrimYamlIndent {
+			p.yamlIndent = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		}
+		if currentTest := p.current(); currentTest != nil {
+			currentTest.EndLine = p.lineNumber
+		} else {
+			// YAML that appears before a test definition is undefined behavior.
+			p.violation(p.lineNumber, YamlBeforeTest, line)
+		}
+	} else {
+		diagnosticMatch := diagnostic.FindStringSubmatch(line)
+		if diagnosticMatch != nil {
+			diagnosticLine := strings.TrimSpace(diagnosticMatch[1])
+			if diagnosticLine == "" && !p.options.KeepBlankDiagnostics {
+				return
 			}
-			if !foundTestPlan {
-				testPlan := testPlanDeclaration.FindStringSubmatch(line)
-				if testPlan != nil {
-					results.ExpectedTests, err = strconv.Atoi(testPlan[1])
-					if err != nil {
-						// malformed test plan; keep looking
-						continue
-					}
-				}
+			p.storeDiagnostic(p.lineNumber, line, diagnosticLine)
+		} else {
+			if strings.TrimSpace(line) != "" {
+				p.violation(p.lineNumber, UnrecognizedLine, line)
 			}
-			testLineMatch := testLine.FindStringSubmatch(line)
-			if testLineMatch != nil {
-				// Store the one we were previously working with, and start a new one.
-				if currentTest != nil {
-					results.Tests = append(results.Tests, *currentTest)
-				}
-				currentTest = &Test{}
-				if foundAllTests {
-					// We've already found all the tests in the plan, so don't waste effort looking
-					// for more. The only reason not to break here instead is because we might want
-					// to parse any diagnostics following the test result output.
-					continue
-				}
-				optionalContentMatch := optionalTestLine.FindStringSubmatch(testLineMatch[2])
-				directive := optionalContentMatch[5]
-				directiveText := optionalContentMatch[4]
-				testNumString := optionalContentMatch[1]
-				if testNumString != "" {
-					currentTest.TestNumber, err = strconv.Atoi(testNumString)
-					if err != nil {
-						currentTest.TestNumber = -1
-					}
-				}
-				description := strings.TrimSpace(optionalContentMatch[2])
-				currentTest.Description = description
-				isFailed := testLineMatch[1] == "not "
-				// Process special cases first; they should not count toward the pass/fail count.
-				results.TotalTests++
-				if directive != "" {
-					currentTest.DirectiveText = directiveText
-				}
-				if strings.EqualFold(directive, "skip") {
-					results.SkippedTests++
-					currentTest.Skipped = true
-				} else if strings.EqualFold(directive, "todo") {
-					results.TodoTests++
-					currentTest.Todo = true
-				} else if isFailed {
-					results.FailedTests++
-					currentTest.Failed = true
-				} else {
-					results.PassedTests++
-					currentTest.Passed = true
-				}
-				if results.TotalTests == results.ExpectedTests {
-					foundAllTests = true
-				}
-			} else if yamlStart.MatchString(line) {
-				state = storeYaml
-				continue
-			} else {
-				diagnosticMatch := diagnostic.FindStringSubmatch(line)
-				if diagnosticMatch != nil {
-					diagnosticLine := strings.TrimSpace(diagnosticMatch[1])
-					if diagnosticLine == "" {
-						continue
-					}
-					if currentTest != nil {
-						currentTest.Diagnostics = append(currentTest.Diagnostics, diagnosticLine)
-					} else {
-						results.Explanation = append(results.Explanation, diagnosticLine)
-					}
-				}
+			p.emit(Event{Type: UnknownEvent, Text: line})
+		}
+	}
+}
+
+// storeDiagnostic attaches the specified diagnostic, found on the specified line, to the current
+// test (or to the explanation, if no tests have been found).
+func (p *Parser) storeDiagnostic(lineNumber int, line string, diagnosticLine string) {
+	if currentTest := p.current(); currentTest != nil {
+		currentTest.Diagnostics = append(currentTest.Diagnostics, diagnosticLine)
+		currentTest.EndLine = lineNumber
+	} else {
+		p.results.Explanation = append(p.results.Explanation, diagnosticLine)
+	}
+	if p.handler == nil {
+		return
+	}
+	p.emitAt(lineNumber, Event{
+		Type:       DiagnosticEvent,
+		Text:       line,
+		Diagnostic: diagnosticLine,
+		Test:       p.currentCopy(),
+	})
+}
+
+// count adds the specified test to the test counts.
+func (p *Parser) count(test *Test) {
+	results := p.results
+	results.TotalTests++
+	switch {
+	case test.Skipped:
+		results.SkippedTests++
+	case test.Todo:
+		results.TodoTests++
+		if test.Ok {
+			results.UnexpectedlyPassingTodos++
+			if p.options.FailPassingTodos {
+				p.problem(test.StartLine, SeverityError, PassingTodo, test.Raw)
 			}
-		case storeYaml:
-			if yamlStop.MatchString(line) {
-				state = storeTestMetadata
+		}
+	case test.Failed:
+		results.FailedTests++
+	default:
+		results.PassedTests++
+	}
+	if results.TotalTests == results.ExpectedTests {
+		p.foundAllTests = true
+	}
+}
+
+// uncount removes the specified test from the test counts.
+func (p *Parser) uncount(test *Test) {
+	results := p.results
+	results.TotalTests--
+	switch {
+	case test.Skipped:
+		results.SkippedTests--
+	case test.Todo:
+		results.TodoTests--
+		if test.Ok {
+			results.UnexpectedlyPassingTodos--
+		}
+	case test.Failed:
+		results.FailedTests--
+	default:
+		results.PassedTests--
+	}
+}
+
+// reportExtraTest records an ExtraTest problem for the test line at the specified line number,
+// according to the extra test policy.
+func (p *Parser) reportExtraTest(line int, text string) {
+	p.results.ExtraTests++
+	if p.options.ExtraTests == FailExtraTests {
+		p.problem(line, SeverityError, ExtraTest, text)
+	} else {
+		p.violation(line, ExtraTest, text)
+	}
+}
+
+// findLateExtraTests finds any tests beyond the number in the plan, if the plan was found after
+// them (as it is when the producer only declares the plan once it has finished), and handles them
+// according to the extra test policy. Tests which follow the plan are found as they are parsed.
+func (p *Parser) findLateExtraTests() {
+	results := p.results
+	if !p.foundTestPlan || results.TotalTests <= results.ExpectedTests {
+		return
+	}
+	found := 0
+	tests := results.Tests[:0]
+	for i := range results.Tests {
+		test := results.Tests[i]
+		if !test.Extra {
+			found++
+		}
+		if found > results.ExpectedTests && !test.Extra {
+			p.uncount(&test)
+			p.reportExtraTest(test.StartLine, test.Raw)
+			if p.options.ExtraTests == IgnoreExtraTests {
 				continue
-			} else {
-				// YAML that appears before a test definition is undefined behavior.
-				if currentTest != nil {
-					// The Go YAML library expects a []byte, so store it that way for later usage.
-					currentTest.YamlBytes = append(currentTest.YamlBytes, line...)
-					currentTest.YamlBytes = append(currentTest.YamlBytes, "\n"...)
-				}
 			}
+			test.Extra = true
 		}
+		tests = append(tests, test)
 	}
-	// if we have a currentTest at this point, it hasn't been saved to the results yet,
-	// since we weren't sure if an upcoming line would have been relevant to it or not.
-	if currentTest != nil {
-		results.Tests = append(results.Tests, *currentTest)
+	results.Tests = tests
+}
+
+// recordTestNumber keeps track of the test numbers found, so that any which are duplicated, out of
+// order or missing can be reported. When parsing strictly, a test number which is not the next in
+// the sequence is a violation.
+func (p *Parser) recordTestNumber(testNumber int, line string) {
+	results := p.results
+	count := p.testNumbers[testNumber]
+	if count == 1 {
+		results.DuplicateTestNumbers = append(results.DuplicateTestNumbers, testNumber)
+	}
+	if testNumber < p.highestTestNumber {
+		results.OutOfOrderTests = append(results.OutOfOrderTests, testNumber)
+	}
+	if p.options.Strict {
+		if count > 0 {
+			p.problem(p.lineNumber, SeverityError, DuplicateTestNumber, line)
+		} else if testNumber != len(results.Tests) {
+			p.problem(p.lineNumber, SeverityError, OutOfSequenceTest, line)
+		}
+	}
+	p.testNumbers[testNumber] = count + 1
+	if testNumber > p.highestTestNumber {
+		p.highestTestNumber = testNumber
 	}
-	return results
+}
+
+// maxMissingTests limits the number of missing tests recorded, beyond the number of tests found,
+// so that a single line (such as "1..50000000") cannot exhaust memory.
+const maxMissingTests = 1000
+
+// findMissingTests records each test number which was not found, up to the number of tests in
+// the plan (or the highest test number found, if there was no plan). If requested, a placeholder
+// test is added for each missing test in the plan. At most maxMissingTests more test numbers than
+// the number of tests found are recorded; if there are more, a TooManyMissingTests problem is
+// recorded.
+func (p *Parser) findMissingTests() {
+	results := p.results
+	last := results.ExpectedTests
+	if !p.foundTestPlan {
+		last = p.highestTestNumber
+	}
+	limit := len(p.testNumbers) + maxMissingTests
+	for testNumber := 1; testNumber <= last; testNumber++ {
+		if p.testNumbers[testNumber] > 0 {
+			continue
+		}
+		if len(results.MissingTestNumbers) == limit {
+			p.problem(0, SeverityWarning, TooManyMissingTests, "")
+			return
+		}
+		results.MissingTestNumbers = append(results.MissingTestNumbers, testNumber)
+		if p.options.MissingTestPlaceholders && testNumber <= results.ExpectedTests {
+			results.Tests = append(results.Tests, Test{
+				TestNumber: testNumber,
+				Failed:     true,
+				Missing:    true,
+			})
+		}
+	}
+}
+
+func (p *Parser) storeYaml(line string) {
+	if yamlStop.MatchString(line) {
+		p.state = storeTestMetadata
+		if currentTest := p.current(); currentTest != nil {
+			currentTest.EndLine = p.lineNumber
+		}
+		if p.handler == nil {
+			return
+		}
+		event := Event{Type: YamlEvent, Text: line, Test: p.currentCopy()}
+		if event.Test != nil {
+			event.YamlBytes = event.Test.YamlBytes
+		}
+		p.emit(event)
+		return
+	}
+	if currentTest := p.current(); currentTest != nil {
+		currentTest.EndLine = p.lineNumber
+		// The Go YAML library expects a []byte, so store it that way for later usage.
+		currentTest.YamlBytes = append(currentTest.YamlBytes, strings.TrimPrefix(line, p.yamlIndent)...)
+		currentTest.YamlBytes = append(currentTest.YamlBytes, "\n"...)
+	}
+}
+
+// Parse interprets the specified lines as output lines from a program that generate TAP output,
+// and returns a corresponding Results structure containing the test results based on its
+// interpretation.
+func Parse(lines []string) *Results {
+	return ParseWithOptions(lines, ParseOptions{})
+}
+
+// ParseWithOptions interprets the specified lines as output lines from a program that generates
+// TAP output, according to the specified options, and returns the corresponding Results.
+func ParseWithOptions(lines []string, options ParseOptions) *Results {
+	parser := NewParserWithOptions(options, nil)
+	for _, line := range lines {
+		parser.Feed(line)
+	}
+	return parser.Finish()
+}
+
+// ParseReader interprets each line read from the specified io.Reader as output lines from a
+// program that generates TAP output, and returns the corresponding Results. An error is returned
+// if the input could not be read; the Results will reflect any lines read before the error.
+func ParseReader(r io.Reader) (*Results, error) {
+	parser := NewParser(nil)
+	_, err := parser.ReadFrom(r)
+	return parser.Finish(), err
 }
@@ -1,6 +1,8 @@
 package tap13
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -92,9 +94,93 @@ ok`,
 		assert.Equal(t, 4, result.TotalTests)
 		assert.Equal(t, 4, result.ExpectedTests)
 		assert.Equal(t, ` Overall result: PASS
+    Extra tests: 1
    Passed tests: 4
 `, result.String())
 	})
+	t.Run("ExtraTestsAreRecordedWithoutBeingCounted", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+1..1
+ok 1 first
+not ok 2 second
+# extra diagnostic
+ok 3 third # SKIP later`,
+			"\n")
+		result := Parse(input)
+		assert.True(t, result.IsPassing())
+		assert.Equal(t, 1, result.TotalTests)
+		assert.Equal(t, 0, result.FailedTests)
+		assert.Equal(t, 2, result.ExtraTests)
+		assert.Equal(t, []Test{
+			{TestNumber: 1, Ok: true, Passed: true, Description: "first",
+				StartLine: 3, EndLine: 3, Raw: input[2]},
+			{
+				TestNumber:  2,
+				Failed:      true,
+				Description: "second",
+				Extra:       true,
+				Diagnostics: []string{"extra diagnostic"},
+				StartLine:   4,
+				EndLine:     5,
+				Raw:         input[3],
+			},
+			{
+				TestNumber:    3,
+				Ok:            true,
+				Skipped:       true,
+				Description:   "third",
+				DirectiveText: "SKIP later",
+				Extra:         true,
+				StartLine:     6,
+				EndLine:       6,
+				Raw:           input[5],
+			},
+		}, result.Tests)
+		result = ParseWithOptions(input, ParseOptions{ExtraTests: IgnoreExtraTests})
+		assert.True(t, result.IsPassing())
+		assert.Equal(t, 2, result.ExtraTests)
+		assert.Equal(t, []Test{{TestNumber: 1, Ok: true, Passed: true, Description: "first",
+			StartLine: 3, EndLine: 3, Raw: input[2]}}, result.Tests)
+		assert.Len(t, result.Problems, 2)
+		result = ParseWithOptions(input, ParseOptions{ExtraTests: FailExtraTests})
+		assert.False(t, result.IsPassing())
+		assert.Len(t, result.Tests, 3)
+		assert.Equal(t, []ParseError{
+			{4, SeverityError, ExtraTest, "not ok 2 second"},
+			{6, SeverityError, ExtraTest, "ok 3 third # SKIP later"},
+		}, result.Problems)
+	})
+	t.Run("ExtraTestsBeforeTrailingPlanFollowPolicy", func(t *testing.T) {
+		input := []string{"TAP version 13", "ok 1", "ok 2", "not ok 3", "# extra diagnostic", "1..2"}
+		result := Parse(input)
+		assert.True(t, result.IsPassing())
+		assert.Equal(t, 2, result.TotalTests)
+		assert.Equal(t, 1, result.ExtraTests)
+		assert.Len(t, result.Tests, 3)
+		assert.True(t, result.Tests[2].Extra)
+		result = ParseWithOptions(input, ParseOptions{ExtraTests: IgnoreExtraTests})
+		assert.True(t, result.IsPassing())
+		assert.Equal(t, 1, result.ExtraTests)
+		assert.Len(t, result.Tests, 2)
+		result = ParseWithOptions(input, ParseOptions{ExtraTests: FailExtraTests})
+		assert.False(t, result.IsPassing())
+		assert.Equal(t, 0, result.FailedTests)
+		assert.True(t, result.Tests[2].Extra)
+		assert.Equal(t, []ParseError{{4, SeverityError, ExtraTest, "not ok 3"}}, result.Problems)
+	})
+	t.Run("IgnoredExtraTestsDoNotShareDiagnostics", func(t *testing.T) {
+		var diagnostics [][]string
+		parser := NewParserWithOptions(ParseOptions{ExtraTests: IgnoreExtraTests}, func(event Event) {
+			if event.Type == DiagnosticEvent {
+				diagnostics = append(diagnostics, event.Test.Diagnostics)
+			}
+		})
+		for _, line := range []string{"TAP version 13", "1..1", "ok 1", "ok 2", "# a", "ok 3", "# b"} {
+			parser.Feed(line)
+		}
+		parser.Finish()
+		assert.Equal(t, [][]string{{"a"}, {"b"}}, diagnostics)
+	})
 	t.Run("SkipAndTodoTestsShouldBeIgnoredButCountedAndDirectivesSaved", func(t *testing.T) {
 		input := strings.Split(`TAP version 13
 1..5
@@ -121,7 +207,38 @@ Total tests run: 5
    Passed tests: 1
   Skipped tests: 2
      TODO tests: 2
+    TODO passed: 1
+`, result.String())
+		assert.Equal(t, 1, result.UnexpectedlyPassingTodos)
+		passing := result.UnexpectedlyPassingTodoTests()
+		assert.Len(t, passing, 1)
+		assert.Equal(t, "TODO working on this one", passing[0].DirectiveText)
+	})
+	t.Run("PassingTodoTestsCanFail", func(t *testing.T) {
+		input := []string{"TAP version 13", "1..2", "ok 1 # TODO", "not ok 2 # TODO"}
+		result := ParseWithOptions(input, ParseOptions{FailPassingTodos: true})
+		assert.False(t, result.IsPassing())
+		assert.Equal(t, 1, result.UnexpectedlyPassingTodos)
+		assert.Equal(t, []ParseError{{3, SeverityError, PassingTodo, "ok 1 # TODO"}}, result.Problems)
+		assert.Contains(t, result.String(), " Overall result: FAIL\n")
+	})
+	t.Run("SkippedPlanShouldPass", func(t *testing.T) {
+		result := Parse(util.ReadFile("testdata/skipping_everything.tap13"))
+		assert.True(t, result.IsPassing())
+		assert.Empty(t, result.Problems)
+		assert.True(t, result.PlanSkipped)
+		assert.Equal(t, "because English-to-French translator isn't installed",
+			result.PlanSkipReason)
+		assert.Equal(t, ` Overall result: PASS
+   Plan skipped: because English-to-French translator isn't installed
 `, result.String())
+		result = Parse([]string{"TAP version 13", "1..0", "not ok 1"})
+		assert.True(t, result.IsPassing())
+		assert.True(t, result.PlanSkipped)
+		assert.Equal(t, 1, result.ExtraTests)
+		result = Parse([]string{"TAP version 13", "1..2 # SKIP not allowed", "ok 1"})
+		assert.False(t, result.PlanSkipped)
+		assert.Equal(t, MalformedPlan, result.Problems[0].Code)
 	})
 	t.Run("MalformedPlansShouldBeIgnored", func(t *testing.T) {
 		input := strings.Split(`TAP version 13
@@ -141,6 +258,7 @@ ok`,
 		assert.Equal(t, 4, result.TotalTests)
 		assert.Equal(t, 4, result.ExpectedTests)
 		assert.Equal(t, ` Overall result: PASS
+    Extra tests: 1
    Passed tests: 4
 `, result.String())
 	})
@@ -186,6 +304,56 @@ ok 3`,
 		assert.Equal(t, 1, result.Tests[2].TestNumber)
 		assert.Equal(t, 3, result.Tests[3].TestNumber)
 	})
+	t.Run("TracksTestNumbering", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+1..6
+ok 1
+ok 2
+ok 5
+ok 2
+ok 1`,
+			"\n")
+		result := Parse(input)
+		assert.Equal(t, []int{3, 4, 6}, result.MissingTestNumbers)
+		assert.Equal(t, []int{2, 1}, result.DuplicateTestNumbers)
+		assert.Equal(t, []int{2, 1}, result.OutOfOrderTests)
+		assert.Len(t, result.Tests, 5)
+		result = Parse([]string{"TAP version 13", "ok 4", "ok", "ok"})
+		assert.Equal(t, []int{1}, result.MissingTestNumbers)
+		assert.Equal(t, []int{2, 3}, result.OutOfOrderTests)
+		assert.Empty(t, result.DuplicateTestNumbers)
+	})
+	t.Run("LimitsMissingTestNumbers", func(t *testing.T) {
+		result := Parse([]string{"TAP version 13", "ok 50000000"})
+		assert.Len(t, result.MissingTestNumbers, 1001)
+		assert.Equal(t, 1001, result.MissingTestNumbers[1000])
+		assert.Equal(t, []ParseError{{0, SeverityWarning, TooManyMissingTests, ""}}, result.Problems)
+		input := []string{"TAP version 13", "1..50000000", "ok 1"}
+		result = ParseWithOptions(input, ParseOptions{MissingTestPlaceholders: true})
+		assert.Len(t, result.MissingTestNumbers, 1001)
+		assert.Len(t, result.Tests, 1002)
+		assert.False(t, result.IsPassing())
+		result = Parse([]string{"TAP version 13", "1..2", "ok 1", "ok 2", "ok 5"})
+		assert.Empty(t, result.MissingTestNumbers)
+	})
+	t.Run("AddsPlaceholdersForMissingTests", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+1..4
+ok 1
+ok 2`,
+			"\n")
+		result := ParseWithOptions(input, ParseOptions{MissingTestPlaceholders: true})
+		assert.Equal(t, []int{3, 4}, result.MissingTestNumbers)
+		assert.Equal(t, []Test{
+			{TestNumber: 1, Ok: true, Passed: true, StartLine: 3, EndLine: 3, Raw: "ok 1"},
+			{TestNumber: 2, Ok: true, Passed: true, StartLine: 4, EndLine: 4, Raw: "ok 2"},
+			{TestNumber: 3, Failed: true, Missing: true},
+			{TestNumber: 4, Failed: true, Missing: true},
+		}, result.Tests)
+		assert.Equal(t, 2, result.TotalTests)
+		assert.Equal(t, 0, result.FailedTests)
+		assert.False(t, result.IsPassing())
+	})
 
 	t.Run("TestsCanHaveDescriptions", func(t *testing.T) {
 		input := strings.Split(`TAP version 13
@@ -203,6 +371,22 @@ ok foo bar # squirrel!`,
 		assert.Equal(t, "baz", result.Tests[2].Description)
 		assert.Equal(t, "foo bar", result.Tests[3].Description)
 	})
+	t.Run("DescriptionsAndDirectivesCanContainEscapes", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+ok 1 - hash \# in name
+ok 2 - backslash \\# SKIP issue \#12
+not ok 3 - C:\temp \\\# # TODO later`,
+			"\n")
+		result := Parse(input)
+		assert.Equal(t, "- hash # in name", result.Tests[0].Description)
+		assert.Equal(t, "", result.Tests[0].DirectiveText)
+		assert.True(t, result.Tests[0].Passed)
+		assert.Equal(t, "- backslash \\", result.Tests[1].Description)
+		assert.Equal(t, "SKIP issue #12", result.Tests[1].DirectiveText)
+		assert.True(t, result.Tests[1].Skipped)
+		assert.Equal(t, "- C:\\temp \\#", result.Tests[2].Description)
+		assert.True(t, result.Tests[2].Todo)
+	})
 	t.Run("SkipContentBeforeVersionAndSkipWackyVersions", func(t *testing.T) {
 		input := strings.Split(`Test results:
 TAP version 999999999999999999999999999999999999999999999999999999999999999999
@@ -352,6 +536,119 @@ ok`,
 		assert.Equal(t, []byte("     yaml:\n       foo: 1\n\n       bar: 2\n"),
 			result.Tests[0].YamlBytes)
 	})
+	t.Run("MapsElementsToLines", func(t *testing.T) {
+		input := strings.Split(`garbage
+TAP version 14
+1..3
+# explanation
+ok 1 - first
+# Subtest: second
+    ok 1 - inner
+    # inner diagnostic
+    1..1
+not ok 2 - second
+  ---
+  message: failed
+  ...
+
+# trailing diagnostic
+Bail out! no more`,
+			"\n")
+		result := Parse(input)
+		assert.Equal(t, 2, result.VersionLine)
+		assert.Equal(t, 3, result.PlanLine)
+		assert.Equal(t, 16, result.BailOutLine)
+		first, second := result.Tests[0], result.Tests[1]
+		assert.Equal(t, []int{5, 5}, []int{first.StartLine, first.EndLine})
+		assert.Equal(t, "ok 1 - first", first.Raw)
+		assert.Equal(t, []int{10, 15}, []int{second.StartLine, second.EndLine})
+		assert.Equal(t, "not ok 2 - second", second.Raw)
+		inner := second.Subtests.Tests[0]
+		assert.Equal(t, []int{7, 8}, []int{inner.StartLine, inner.EndLine})
+		assert.Equal(t, "ok 1 - inner", inner.Raw)
+		assert.Equal(t, 9, second.Subtests.PlanLine)
+		assert.Equal(t, 0, second.Subtests.VersionLine)
+	})
+	t.Run("AcceptsOnlyConfiguredVersions", func(t *testing.T) {
+		input := []string{"TAP version 12", "TAP version 14", "1..1", "ok 1"}
+		result := ParseWithOptions(input, ParseOptions{Versions: []int{13, 14}})
+		assert.True(t, result.IsPassing())
+		assert.Equal(t, 14, result.TapVersion)
+		assert.Equal(t, 2, result.VersionLine)
+		assert.Equal(t, []ParseError{
+			{1, SeverityWarning, UnsupportedVersion, "TAP version 12"},
+		}, result.Problems)
+		result = ParseWithOptions(input[:1], ParseOptions{Versions: []int{13}})
+		assert.False(t, result.FoundTapData)
+	})
+	t.Run("ToleratesMissingVersion", func(t *testing.T) {
+		lines := util.ReadFile("testdata/creative_liberties.tap12")
+		assert.Equal(t, "TAP version 12", lines[0])
+		result := Parse(lines[1:])
+		assert.False(t, result.FoundTapData)
+		result = ParseWithOptions(lines[1:], ParseOptions{AllowMissingVersion: true})
+		expected := Parse(lines)
+		assert.True(t, result.FoundTapData)
+		assert.Equal(t, 12, result.TapVersion)
+		assert.Equal(t, expected.IsPassing(), result.IsPassing())
+		assert.Equal(t, expected.TotalTests, result.TotalTests)
+		assert.Equal(t, expected.PassedTests, result.PassedTests)
+		assert.Equal(t, expected.FailedTests, result.FailedTests)
+	})
+	t.Run("KeepsBlankDiagnostics", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+# first
+#
+# second
+not ok 1
+# got:
+#
+#   1`,
+			"\n")
+		result := Parse(input)
+		assert.Equal(t, []string{"first", "second"}, result.Explanation)
+		assert.Equal(t, []string{"got:", "1"}, result.Tests[0].Diagnostics)
+		result = ParseWithOptions(input, ParseOptions{KeepBlankDiagnostics: true})
+		assert.Equal(t, []string{"first", "", "second"}, result.Explanation)
+		assert.Equal(t, []string{"got:", "", "1"}, result.Tests[0].Diagnostics)
+		assert.Equal(t, 8, result.Tests[0].EndLine)
+	})
+	t.Run("TrimsYamlIndent", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+not ok 1
+  ---
+  message: values differ
+  got:
+    - 1
+  ...`,
+			"\n")
+		result := ParseWithOptions(input, ParseOptions{TrimYamlIndent: true})
+		assert.Equal(t, []byte("message: values differ\ngot:\n  - 1\n"), result.Tests[0].YamlBytes)
+		diagnostic, err := result.Tests[0].Diagnostic()
+		assert.NoError(t, err)
+		assert.Equal(t, "values differ", diagnostic.Message)
+	})
+	t.Run("TruncatesLinesBetweenCharacters", func(t *testing.T) {
+		input := []string{"TAP version 13", "ok 1 - crème brûlée"}
+		result := ParseWithOptions(input, ParseOptions{MaxLineLength: 17})
+		assert.Equal(t, "ok 1 - crème br", result.Tests[0].Raw)
+		assert.Equal(t, []ParseError{{2, SeverityWarning, LineTooLong, "ok 1 - crème br"}},
+			result.Problems)
+		result = ParseWithOptions(input, ParseOptions{MaxLineLength: 18})
+		assert.Equal(t, "ok 1 - crème brû", result.Tests[0].Raw)
+	})
+	t.Run("LimitsLinesAndLineLength", func(t *testing.T) {
+		input := []string{"TAP version 13", "1..3", "ok 1 - a very long description", "ok 2", "ok 3"}
+		result := ParseWithOptions(input, ParseOptions{MaxLines: 4, MaxLineLength: 14})
+		assert.False(t, result.IsPassing())
+		assert.Equal(t, 2, result.TotalTests)
+		assert.Equal(t, "- a very", result.Tests[0].Description)
+		assert.Equal(t, []string{"TAP version 13", "1..3", "ok 1 - a very ", "ok 2"}, result.Lines)
+		assert.Equal(t, []ParseError{
+			{3, SeverityWarning, LineTooLong, "ok 1 - a very "},
+			{5, SeverityError, TooManyLines, "ok 3"},
+		}, result.Problems)
+	})
 	t.Run("InvalidInputFile", func(t *testing.T) {
 		input := strings.Split(`Not a TAP version 13 file!
 No TAP here.
@@ -362,3 +659,87 @@ What were you expecting? An error?`,
 	})
 
 }
+
+func TestStreamingParser(t *testing.T) {
+	t.Run("EmitsEventsInOrder", func(t *testing.T) {
+		var events []Event
+		parser := NewParser(func(e Event) {
+			events = append(events, e)
+		})
+		for _, line := range strings.Split(`Preamble
+TAP version 13
+1..2
+# Explanation
+ok 1 first
+  ---
+  foo: 1
+  ...
+not ok 2 second
+# Failed because of reasons
+Bail out! Out of towels.`, "\n") {
+			parser.Feed(line)
+		}
+		result := parser.Finish()
+		var types []EventType
+		for _, e := range events {
+			types = append(types, e.Type)
+		}
+		assert.Equal(t, []EventType{
+			UnknownEvent,
+			VersionEvent,
+			PlanEvent,
+			DiagnosticEvent,
+			TestEvent,
+			YamlEvent,
+			TestEvent,
+			DiagnosticEvent,
+			BailOutEvent,
+		}, types)
+		assert.Equal(t, 2, events[1].Line)
+		assert.Equal(t, 13, events[1].Version)
+		assert.Equal(t, 2, events[2].ExpectedTests)
+		assert.Nil(t, events[3].Test)
+		assert.Equal(t, "Explanation", events[3].Diagnostic)
+		assert.Equal(t, "first", events[4].Test.Description)
+		assert.Equal(t, []byte("  foo: 1\n"), events[5].YamlBytes)
+		assert.Equal(t, 8, events[5].Line)
+		assert.True(t, events[6].Test.Failed)
+		assert.Equal(t, "second", events[7].Test.Description)
+		assert.Equal(t, "Out of towels.", events[8].BailOutReason)
+		assert.Equal(t, 2, result.TotalTests)
+		assert.Equal(t, []string{"Failed because of reasons"}, result.Tests[1].Diagnostics)
+		assert.Len(t, result.Lines, 11)
+	})
+	t.Run("ResultsAreAvailableWhileParsing", func(t *testing.T) {
+		parser := NewParser(nil)
+		parser.Feed("TAP version 13")
+		parser.Feed("1..2")
+		parser.Feed("ok")
+		assert.Equal(t, 1, parser.Results().TotalTests)
+		assert.False(t, parser.Results().IsPassing())
+		parser.Feed("ok")
+		assert.True(t, parser.Finish().IsPassing())
+	})
+	t.Run("ReadsFromReader", func(t *testing.T) {
+		result, err := ParseReader(strings.NewReader(
+			"TAP version 13\r\n1..3\r\nok\nnot ok # TODO\nok"))
+		assert.NoError(t, err)
+		assert.True(t, result.IsPassing())
+		assert.Equal(t, 3, result.TotalTests)
+		assert.Equal(t, []string{"TAP version 13", "1..3", "ok", "not ok # TODO", "ok"},
+			result.Lines)
+	})
+	t.Run("MatchesParseForTestData", func(t *testing.T) {
+		files, err := filepath.Glob("testdata/*.tap*")
+		assert.NoError(t, err)
+		for _, file := range files {
+			lines := util.ReadFile(file)
+			f, err := os.Open(file)
+			assert.NoError(t, err)
+			streamed, err := ParseReader(f)
+			f.Close()
+			assert.NoError(t, err)
+			assert.Equal(t, Parse(lines), streamed, file)
+		}
+	})
+}
@@ -0,0 +1,56 @@
+package tap13
+
+import (
+	"regexp"
+	"strings"
+)
+
+// StrictPragma is the name of the pragma which enables (or disables) strict parsing for the
+// remainder of the TAP stream, as if ParseOptions.Strict had been set.
+const StrictPragma = "strict"
+
+// Pragma records a pragma found in a "pragma +name" (enabled) or "pragma -name" (disabled) line.
+// The Line field contains the 1-based line number on which it was found.
+type Pragma struct {
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+	Line    int    `json:"line"`
+}
+
+// PragmaEnabled checks if the most recent pragma with the specified name enabled it. A pragma
+// which was never found is not enabled.
+func (r *Results) PragmaEnabled(name string) bool {
+	enabled := false
+	for _, pragma := range r.Pragmas {
+		if pragma.Name == name {
+			enabled = pragma.Enabled
+		}
+	}
+	return enabled
+}
+
+var pragmaLine = regexp.MustCompile(`^pragma\s+([+-]\w[\w-]*(?:\s*,\s*[+-]\w[\w-]*)*)\s*$`)
+
+// storePragmas records each pragma in the specified line, if it is a pragma line.
+func (p *Parser) storePragmas(line string) bool {
+	pragmaMatch := pragmaLine.FindStringSubmatch(line)
+	if pragmaMatch == nil {
+		return false
+	}
+	for _, setting := range strings.Split(pragmaMatch[1], ",") {
+		setting = strings.TrimSpace(setting)
+		pragma := Pragma{Name: setting[1:], Enabled: setting[0] == '+', Line: p.lineNumber}
+		p.results.Pragmas = append(p.results.Pragmas, pragma)
+		if pragma.Name == StrictPragma {
+			p.options.Strict = pragma.Enabled
+		} else if hook, ok := p.options.Pragmas[pragma.Name]; ok {
+			if hook != nil {
+				hook(pragma)
+			}
+		} else {
+			p.violation(p.lineNumber, UnknownPragma, line)
+		}
+		p.emit(Event{Type: PragmaEvent, Text: line, Pragma: pragma})
+	}
+	return true
+}
@@ -0,0 +1,82 @@
+package tap13
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPragmas(t *testing.T) {
+	t.Run("RecordsPragmasInOrder", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+pragma +strict
+1..2
+ok 1
+pragma -strict, +custom
+ok 2`,
+			"\n")
+		result := ParseWithOptions(input, ParseOptions{
+			Pragmas: map[string]func(Pragma){"custom": nil},
+		})
+		assert.Equal(t, []Pragma{
+			{Name: "strict", Enabled: true, Line: 2},
+			{Name: "strict", Enabled: false, Line: 5},
+			{Name: "custom", Enabled: true, Line: 5},
+		}, result.Pragmas)
+		assert.Empty(t, result.Problems)
+		assert.True(t, result.IsPassing())
+		assert.False(t, result.PragmaEnabled("strict"))
+		assert.True(t, result.PragmaEnabled("custom"))
+		assert.False(t, result.PragmaEnabled("missing"))
+	})
+	t.Run("StrictPragmaEnablesStrictParsing", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+ok 2
+pragma +strict
+ok 2
+pragma -strict
+ok 2
+1..3`,
+			"\n")
+		result := Parse(input)
+		assert.Equal(t, []ParseError{
+			{4, SeverityError, DuplicateTestNumber, "ok 2"},
+		}, result.Problems)
+		assert.False(t, result.IsPassing())
+		input = []string{"TAP version 13", "pragma -strict", "ok 2", "ok 2"}
+		result = ParseWithOptions(input, ParseOptions{Strict: true})
+		assert.Empty(t, result.Problems)
+	})
+	t.Run("CallsHookForCustomPragmas", func(t *testing.T) {
+		var pragmas []Pragma
+		var events []Event
+		parser := NewParserWithOptions(ParseOptions{
+			Pragmas: map[string]func(Pragma){
+				"color": func(pragma Pragma) { pragmas = append(pragmas, pragma) },
+			},
+		}, func(event Event) {
+			if event.Type == PragmaEvent {
+				events = append(events, event)
+			}
+		})
+		for _, line := range []string{"TAP version 13", "pragma +color", "pragma -unknown"} {
+			parser.Feed(line)
+		}
+		result := parser.Finish()
+		assert.Equal(t, []Pragma{{Name: "color", Enabled: true, Line: 2}}, pragmas)
+		assert.Len(t, events, 2)
+		assert.Equal(t, Pragma{Name: "unknown", Enabled: false, Line: 3}, events[1].Pragma)
+		assert.Equal(t, []ParseError{
+			{3, SeverityWarning, UnknownPragma, "pragma -unknown"},
+		}, result.Problems)
+	})
+	t.Run("WriterWritesPragmas", func(t *testing.T) {
+		var buffer bytes.Buffer
+		w := NewWriter(&buffer)
+		assert.NoError(t, w.Pragma("strict", true))
+		assert.NoError(t, w.Pragma("color", false))
+		assert.Equal(t, "TAP version 13\npragma +strict\npragma -color\n", buffer.String())
+	})
+}
@@ -0,0 +1,116 @@
+package tap13
+
+import "fmt"
+
+// Severity indicates how serious a problem found while parsing is.
+type Severity int
+
+const (
+	// SeverityWarning indicates input which was tolerated, but which may not have been
+	// interpreted the way its producer intended.
+	SeverityWarning Severity = iota
+	// SeverityError indicates input which prevented the test run from being interpreted, or
+	// which violated the TAP specification while parsing strictly. A test run with any problem
+	// of this severity is considered failing.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// MarshalText encodes the severity as its name, such as "warning".
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText decodes a severity encoded by MarshalText.
+func (s *Severity) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "warning":
+		*s = SeverityWarning
+	case "error":
+		*s = SeverityError
+	default:
+		return fmt.Errorf("tap13: unknown severity %q", text)
+	}
+	return nil
+}
+
+// ProblemCode is a machine-readable identifier for the kind of problem found while parsing.
+type ProblemCode string
+
+const (
+	// MissingVersion indicates that no TAP version line was found.
+	MissingVersion ProblemCode = "missing-version"
+	// MalformedVersion indicates a TAP version line whose version number could not be parsed.
+	MalformedVersion ProblemCode = "malformed-version"
+	// UnsupportedVersion indicates a TAP version line declaring a version which is not one of
+	// ParseOptions.Versions; it is ignored.
+	UnsupportedVersion ProblemCode = "unsupported-version"
+	// MalformedPlan indicates a line which looks like a test plan, but could not be parsed.
+	MalformedPlan ProblemCode = "malformed-plan"
+	// DuplicatePlan indicates a test plan found after the first; it is ignored.
+	DuplicatePlan ProblemCode = "duplicate-plan"
+	// MalformedTestNumber indicates a test line whose test number could not be parsed.
+	MalformedTestNumber ProblemCode = "malformed-test-number"
+	// ExtraTest indicates a test line found after all the tests in the plan were found.
+	ExtraTest ProblemCode = "extra-test"
+	// YamlBeforeTest indicates a YAML block which did not follow a test line; it is ignored.
+	YamlBeforeTest ProblemCode = "yaml-before-test"
+	// UnterminatedYaml indicates a YAML block which was still open at the end of the input.
+	UnterminatedYaml ProblemCode = "unterminated-yaml"
+	// UnterminatedSubtest indicates a subtest which was not followed by a test line.
+	UnterminatedSubtest ProblemCode = "unterminated-subtest"
+	// MissingPlan indicates that no test plan was found; it is only reported when parsing
+	// strictly.
+	MissingPlan ProblemCode = "missing-plan"
+	// MisplacedPlan indicates a test plan found between test lines, rather than before or after
+	// all of them; it is only reported when parsing strictly.
+	MisplacedPlan ProblemCode = "misplaced-plan"
+	// DuplicateTestNumber indicates a test line whose test number was already used; it is only
+	// reported when parsing strictly.
+	DuplicateTestNumber ProblemCode = "duplicate-test-number"
+	// OutOfSequenceTest indicates a test line whose test number is not the next in sequence; it
+	// is only reported when parsing strictly.
+	OutOfSequenceTest ProblemCode = "out-of-sequence-test"
+	// PassingTodo indicates a TODO test which passed; it is only reported if
+	// ParseOptions.FailPassingTodos is set.
+	PassingTodo ProblemCode = "passing-todo"
+	// UnknownPragma indicates a pragma line naming a pragma which is not built in, and which
+	// was not defined using ParseOptions.Pragmas.
+	UnknownPragma ProblemCode = "unknown-pragma"
+	// UnrecognizedLine indicates a line after the TAP version line which is not valid TAP.
+	UnrecognizedLine ProblemCode = "unrecognized-line"
+	// TooManyMissingTests indicates that so many test numbers were missing that only the first
+	// of them were recorded in Results.MissingTestNumbers.
+	TooManyMissingTests ProblemCode = "too-many-missing-tests"
+	// TooManyLines indicates that the input had more lines than ParseOptions.MaxLines; the
+	// remaining lines were ignored.
+	TooManyLines ProblemCode = "too-many-lines"
+	// LineTooLong indicates a line longer than ParseOptions.MaxLineLength; it was truncated.
+	LineTooLong ProblemCode = "line-too-long"
+)
+
+// ParseError describes an anomaly found while parsing TAP output. The Line field contains the
+// 1-based line number of the offending line (or zero if the problem applies to the input as a
+// whole), and Text contains the offending line itself.
+type ParseError struct {
+	Line     int         `json:"line"`
+	Severity Severity    `json:"severity"`
+	Code     ProblemCode `json:"code"`
+	Text     string      `json:"text,omitempty"`
+}
+
+func (e ParseError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("%s: %s", e.Severity, e.Code)
+	}
+	return fmt.Sprintf("line %d: %s: %s: %q", e.Line, e.Severity, e.Code, e.Text)
+}
@@ -0,0 +1,134 @@
+package tap13
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	util "github.com/mpontillo/tap13/internal"
+)
+
+func TestParseProblems(t *testing.T) {
+	t.Run("NoInputIsMissingVersion", func(t *testing.T) {
+		result := Parse(nil)
+		assert.Equal(t, []ParseError{
+			{Line: 0, Severity: SeverityError, Code: MissingVersion},
+		}, result.Problems)
+	})
+	t.Run("WellFormedInputHasNoProblems", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+1..2
+ok 1
+# diagnostic
+
+not ok 2
+  ---
+  foo: bar
+  ...`,
+			"\n")
+		result := Parse(input)
+		assert.Empty(t, result.Problems)
+	})
+	t.Run("ReportsEachAnomalyWithLineNumber", func(t *testing.T) {
+		input := strings.Split(`TAP version 999999999999999999999999999999999999999
+TAP version 13
+  ---
+  early: yaml
+  ...
+1..N
+1..2
+1..3
+ok 400000000000000000000000000000000000000000000000000
+garbage
+ok 2
+ok 3
+  ---
+  unterminated: true`,
+			"\n")
+		result := Parse(input)
+		assert.Equal(t, []ParseError{
+			{1, SeverityWarning, MalformedVersion, input[0]},
+			{3, SeverityWarning, YamlBeforeTest, "  ---"},
+			{6, SeverityWarning, MalformedPlan, "1..N"},
+			{8, SeverityWarning, DuplicatePlan, "1..3"},
+			{9, SeverityWarning, MalformedTestNumber, input[8]},
+			{10, SeverityWarning, UnrecognizedLine, "garbage"},
+			{12, SeverityWarning, ExtraTest, "ok 3"},
+			{13, SeverityWarning, UnterminatedYaml, "  ---"},
+		}, result.Problems)
+		assert.Equal(t, 2, result.ExpectedTests)
+	})
+	t.Run("ReportsGarbageInEdgeCases", func(t *testing.T) {
+		result := Parse(util.ReadFile("testdata/edge_cases.tap13"))
+		assert.Len(t, result.Problems, 2)
+		assert.Equal(t, 90, result.Problems[0].Line)
+		assert.Equal(t, UnrecognizedLine, result.Problems[0].Code)
+		assert.Equal(t, "[some garbage that should be ignored]: xxx", result.Problems[0].Text)
+	})
+	t.Run("StrictModeReportsViolationsAsErrors", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+ok 1
+1..4
+ok 3
+ok 3
+1..4
+ok 4
+ok 5
+  ---
+  unterminated: true`,
+			"\n")
+		result := Parse(input)
+		assert.Equal(t, []ParseError{
+			{6, SeverityWarning, DuplicatePlan, "1..4"},
+			{8, SeverityWarning, ExtraTest, "ok 5"},
+			{9, SeverityWarning, UnterminatedYaml, "  ---"},
+		}, result.Problems)
+		result = ParseWithOptions(input, ParseOptions{Strict: true})
+		assert.Equal(t, []ParseError{
+			{3, SeverityError, MisplacedPlan, "1..4"},
+			{4, SeverityError, OutOfSequenceTest, "ok 3"},
+			{5, SeverityError, DuplicateTestNumber, "ok 3"},
+			{6, SeverityError, DuplicatePlan, "1..4"},
+			{8, SeverityError, ExtraTest, "ok 5"},
+			{9, SeverityError, UnterminatedYaml, "  ---"},
+		}, result.Problems)
+		assert.False(t, result.IsPassing())
+	})
+	t.Run("StrictModeRequiresPlan", func(t *testing.T) {
+		input := []string{"TAP version 13", "ok 1", "ok 2"}
+		assert.True(t, Parse(input).IsPassing())
+		result := ParseWithOptions(input, ParseOptions{Strict: true})
+		assert.Equal(t, []ParseError{{0, SeverityError, MissingPlan, ""}}, result.Problems)
+		assert.False(t, result.IsPassing())
+		result = ParseWithOptions(append(input, "1..2"), ParseOptions{Strict: true})
+		assert.Empty(t, result.Problems)
+		assert.True(t, result.IsPassing())
+	})
+	t.Run("StrictModeReportsTestsBeyondTrailingPlan", func(t *testing.T) {
+		input := []string{"TAP version 13", "ok 1", "ok 2", "ok 3", "1..2"}
+		result := ParseWithOptions(input, ParseOptions{Strict: true})
+		assert.Equal(t, []ParseError{{4, SeverityError, ExtraTest, "ok 3"}}, result.Problems)
+		assert.Equal(t, 1, result.ExtraTests)
+		assert.Equal(t, 2, result.TotalTests)
+		assert.True(t, result.Tests[2].Extra)
+		assert.False(t, result.IsPassing())
+		result = Parse(input)
+		assert.Equal(t, []ParseError{{4, SeverityWarning, ExtraTest, "ok 3"}}, result.Problems)
+		assert.True(t, result.IsPassing())
+	})
+	t.Run("StrictModeAppliesToSubtests", func(t *testing.T) {
+		input := []string{"TAP version 13", "1..1", "    ok 2", "    1..1", "ok 1"}
+		result := ParseWithOptions(input, ParseOptions{Strict: true})
+		assert.Empty(t, result.Problems)
+		subtest := result.Tests[0].Subtests
+		assert.Equal(t, []ParseError{{3, SeverityError, OutOfSequenceTest, "ok 2"}}, subtest.Problems)
+		assert.False(t, subtest.IsPassing())
+		assert.False(t, result.IsPassing())
+		assert.True(t, Parse(input).IsPassing())
+	})
+	t.Run("FormatsAsError", func(t *testing.T) {
+		err := ParseError{Line: 6, Severity: SeverityWarning, Code: MalformedPlan, Text: "1..N"}
+		assert.Equal(t, `line 6: warning: malformed-plan: "1..N"`, err.Error())
+	})
+}
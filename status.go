@@ -0,0 +1,49 @@
+package tap13
+
+// Status is the outcome of a single test, taking its directive (if any) into account.
+type Status int
+
+const (
+	// StatusPass indicates a passing test ("ok").
+	StatusPass Status = iota
+	// StatusFail indicates a failing test ("not ok").
+	StatusFail
+	// StatusSkip indicates a test which was skipped ("# SKIP"), whether or not it passed.
+	StatusSkip
+	// StatusTodoFail indicates a TODO test ("# TODO") which failed, as expected.
+	StatusTodoFail
+	// StatusTodoPass indicates a TODO test which unexpectedly passed, and so may no longer need
+	// to be marked TODO.
+	StatusTodoPass
+)
+
+var statusNames = map[Status]string{
+	StatusPass:     "pass",
+	StatusFail:     "fail",
+	StatusSkip:     "skip",
+	StatusTodoFail: "todo-fail",
+	StatusTodoPass: "todo-pass",
+}
+
+func (s Status) String() string {
+	if name, ok := statusNames[s]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// Status returns the outcome of the test. Unlike the Todo field, it distinguishes between TODO
+// tests which failed and TODO tests which unexpectedly passed.
+func (t *Test) Status() Status {
+	switch {
+	case t.Skipped:
+		return StatusSkip
+	case t.Todo && t.Ok:
+		return StatusTodoPass
+	case t.Todo:
+		return StatusTodoFail
+	case t.Failed:
+		return StatusFail
+	}
+	return StatusPass
+}
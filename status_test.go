@@ -0,0 +1,41 @@
+package tap13
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatus(t *testing.T) {
+	t.Run("DistinguishesTodoTestsWhichPass", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+1..6
+ok 1
+not ok 2
+ok 3 # SKIP
+not ok 4 # SKIP
+not ok 5 # TODO
+ok 6 # TODO`,
+			"\n")
+		result := Parse(input)
+		var statuses []Status
+		var oks []bool
+		for i := range result.Tests {
+			statuses = append(statuses, result.Tests[i].Status())
+			oks = append(oks, result.Tests[i].Ok)
+		}
+		assert.Equal(t, []Status{
+			StatusPass, StatusFail, StatusSkip, StatusSkip, StatusTodoFail, StatusTodoPass,
+		}, statuses)
+		assert.Equal(t, []bool{true, false, true, false, false, true}, oks)
+	})
+	t.Run("FormatsAsString", func(t *testing.T) {
+		assert.Equal(t, "pass", StatusPass.String())
+		assert.Equal(t, "fail", StatusFail.String())
+		assert.Equal(t, "skip", StatusSkip.String())
+		assert.Equal(t, "todo-fail", StatusTodoFail.String())
+		assert.Equal(t, "todo-pass", StatusTodoPass.String())
+		assert.Equal(t, "unknown", Status(99).String())
+	})
+}
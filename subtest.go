@@ -0,0 +1,118 @@
+package tap13
+
+import (
+	"regexp"
+	"strings"
+)
+
+// subtestIndent is the indentation which denotes a line belonging to a subtest, as specified by
+// TAP version 14 (and used by Test::More and node-tap).
+const subtestIndent = "    "
+
+var subtestHeader = regexp.MustCompile(`^#\s*Subtest(?::\s*(.*?))?\s*$`)
+
+// startsSubtest checks if the specified (unindented) line can begin a subtest that has no
+// "# Subtest" header line.
+func startsSubtest(line string) bool {
+	return testLine.MatchString(line) ||
+		testPlanDeclaration.MatchString(line) ||
+		versionLine.MatchString(line)
+}
+
+// storeSubtest checks if the specified line belongs to a subtest, and if so, passes it to the
+// parser for the subtest in progress (starting a new one if necessary). Returns true if the line
+// was consumed by the subtest.
+func (p *Parser) storeSubtest(line string) bool {
+	if !strings.HasPrefix(line, subtestIndent) {
+		p.flushSubtestHeader()
+		header := subtestHeader.FindStringSubmatch(line)
+		if header == nil {
+			return false
+		}
+		if p.subtest != nil {
+			p.abandonSubtest()
+		}
+		// The header only introduces a subtest if indented lines follow it; otherwise, it is an
+		// ordinary diagnostic.
+		p.headerPending = true
+		p.subtestName = header[1]
+		p.subtestLine = p.lineNumber
+		p.subtestText = line
+		return true
+	}
+	childLine := line[len(subtestIndent):]
+	header := subtestHeader.FindStringSubmatch(childLine)
+	if p.subtest == nil {
+		switch {
+		case p.headerPending:
+			p.startSubtest(p.subtestName, p.subtestLine, p.subtestText)
+		case header != nil:
+			// Test::More places the header at the same indentation as the subtest itself.
+			p.startSubtest(header[1], p.lineNumber, line)
+			return true
+		case startsSubtest(childLine):
+			p.startSubtest("", p.lineNumber, line)
+		default:
+			return false
+		}
+	}
+	if !p.subtestStarted && header != nil && header[1] == p.subtest.results.Name {
+		// Some producers repeat the header at the indentation of the subtest.
+		return true
+	}
+	p.subtestStarted = true
+	// Keep the line numbers of the subtest consistent with the enclosing stream.
+	p.subtest.lineNumber = p.lineNumber - 1
+	p.subtest.Feed(childLine)
+	if p.subtest.results.BailOut && !p.results.BailOut {
+		// Bailing out of a subtest bails out of the entire test run.
+		p.results.BailOut = true
+		p.results.BailOutReason = p.subtest.results.BailOutReason
+		p.results.BailOutLine = p.lineNumber
+	}
+	return true
+}
+
+// startSubtest begins parsing a subtest with the specified name (which may be empty), introduced
+// by the specified line.
+func (p *Parser) startSubtest(name string, lineNumber int, line string) {
+	if p.subtest != nil {
+		p.abandonSubtest()
+	}
+	p.headerPending = false
+	var handler func(Event)
+	if p.handler != nil {
+		handler = func(event Event) {
+			event.Depth++
+			p.handler(event)
+		}
+	}
+	subtest := NewParserWithOptions(p.options, handler)
+	subtest.versionOptional = true
+	subtest.results.FoundTapData = true
+	subtest.results.TapVersion = p.results.TapVersion
+	subtest.results.Name = name
+	p.subtest = subtest
+	p.subtestLine = lineNumber
+	p.subtestText = line
+	p.subtestStarted = false
+	p.emitAt(lineNumber, Event{Type: SubtestEvent, Text: line, Subtest: name})
+}
+
+// flushSubtestHeader stores a "# Subtest" line which was not followed by any indented lines as an
+// ordinary diagnostic.
+func (p *Parser) flushSubtestHeader() {
+	if !p.headerPending {
+		return
+	}
+	p.headerPending = false
+	diagnosticLine := strings.TrimSpace(diagnostic.FindStringSubmatch(p.subtestText)[1])
+	p.storeDiagnostic(p.subtestLine, p.subtestText, diagnosticLine)
+}
+
+// abandonSubtest discards a subtest which was not followed by a test line summarizing its result.
+func (p *Parser) abandonSubtest() {
+	p.subtest.Finish()
+	p.subtest = nil
+	p.violation(p.subtestLine, UnterminatedSubtest, p.subtestText)
+}
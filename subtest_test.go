@@ -0,0 +1,138 @@
+package tap13
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSubtests(t *testing.T) {
+	t.Run("ParsesTap14Subtests", func(t *testing.T) {
+		input := strings.Split(`TAP version 14
+1..2
+# Subtest: foo
+    1..2
+    ok 1 - first
+    not ok 2 - second
+not ok 1 - foo
+ok 2 - bar`,
+			"\n")
+		result := Parse(input)
+		assert.True(t, result.FoundTapData)
+		assert.Equal(t, 14, result.TapVersion)
+		assert.Equal(t, 2, result.TotalTests)
+		assert.Equal(t, 1, result.FailedTests)
+		assert.Nil(t, result.Tests[1].Subtests)
+		subtests := result.Tests[0].Subtests
+		assert.NotNil(t, subtests)
+		assert.Equal(t, "foo", subtests.Name)
+		assert.Equal(t, 14, subtests.TapVersion)
+		assert.Equal(t, 2, subtests.ExpectedTests)
+		assert.Equal(t, 2, subtests.TotalTests)
+		assert.Equal(t, "- first", subtests.Tests[0].Description)
+		assert.True(t, subtests.Tests[1].Failed)
+		assert.False(t, subtests.IsPassing())
+		assert.Empty(t, result.Problems)
+	})
+	t.Run("ParsesIndentedHeadersAndNestedSubtests", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+    # Subtest: outer
+        # Subtest: inner
+        ok 1
+        1..1
+    ok 1 - inner
+    # diagnostic for inner
+    1..1
+ok 1 - outer
+    ok 1
+ok 2
+1..2`,
+			"\n")
+		result := Parse(input)
+		assert.True(t, result.IsPassing())
+		outer := result.Tests[0].Subtests
+		assert.Equal(t, "outer", outer.Name)
+		assert.Equal(t, 1, outer.ExpectedTests)
+		assert.Equal(t, []string{"diagnostic for inner"}, outer.Tests[0].Diagnostics)
+		inner := outer.Tests[0].Subtests
+		assert.Equal(t, "inner", inner.Name)
+		assert.Equal(t, 1, inner.PassedTests)
+		unnamed := result.Tests[1].Subtests
+		assert.Equal(t, "", unnamed.Name)
+		assert.Equal(t, 1, unnamed.TotalTests)
+		assert.Empty(t, result.Problems)
+	})
+	t.Run("IndentedDiagnosticsOutsideSubtestsAreUnchanged", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+ok
+    # still a diagnostic`,
+			"\n")
+		result := Parse(input)
+		assert.Nil(t, result.Tests[0].Subtests)
+		assert.Equal(t, []string{"still a diagnostic"}, result.Tests[0].Diagnostics)
+	})
+	t.Run("HeadersWithoutIndentedLinesAreDiagnostics", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+ok 1 - first
+# Subtest: not really
+ok 2 - second
+# Subtest: at the end`,
+			"\n")
+		var diagnostics []int
+		parser := NewParser(func(e Event) {
+			assert.NotEqual(t, SubtestEvent, e.Type)
+			if e.Type == DiagnosticEvent {
+				diagnostics = append(diagnostics, e.Line)
+			}
+		})
+		for _, line := range input {
+			parser.Feed(line)
+		}
+		result := parser.Finish()
+		assert.True(t, result.IsPassing())
+		assert.Nil(t, result.Tests[1].Subtests)
+		assert.Equal(t, []string{"Subtest: not really"}, result.Tests[0].Diagnostics)
+		assert.Equal(t, 3, result.Tests[0].EndLine)
+		assert.Equal(t, []string{"Subtest: at the end"}, result.Tests[1].Diagnostics)
+		assert.Equal(t, []int{3, 5}, diagnostics)
+		assert.Empty(t, result.Problems)
+	})
+	t.Run("BailOutInSubtestBailsOutOfRun", func(t *testing.T) {
+		input := strings.Split(`TAP version 14
+# Subtest
+    ok 1
+    Bail out! Cannot continue.`,
+			"\n")
+		result := Parse(input)
+		assert.True(t, result.BailOut)
+		assert.Equal(t, "Cannot continue.", result.BailOutReason)
+		assert.False(t, result.IsPassing())
+		assert.Equal(t, []ParseError{
+			{2, SeverityWarning, UnterminatedSubtest, "# Subtest"},
+		}, result.Problems)
+	})
+	t.Run("EmitsSubtestEventsWithDepth", func(t *testing.T) {
+		var events []Event
+		parser := NewParser(func(e Event) {
+			events = append(events, e)
+		})
+		for _, line := range strings.Split(`TAP version 14
+# Subtest: foo
+    ok 1 - first
+ok 1 - foo`, "\n") {
+			parser.Feed(line)
+		}
+		parser.Finish()
+		assert.Len(t, events, 4)
+		assert.Equal(t, SubtestEvent, events[1].Type)
+		assert.Equal(t, "foo", events[1].Subtest)
+		assert.Equal(t, 0, events[1].Depth)
+		assert.Equal(t, TestEvent, events[2].Type)
+		assert.Equal(t, 1, events[2].Depth)
+		assert.Equal(t, 3, events[2].Line)
+		assert.Equal(t, TestEvent, events[3].Type)
+		assert.Equal(t, 0, events[3].Depth)
+		assert.Equal(t, 1, events[3].Test.Subtests.PassedTests)
+	})
+}
@@ -0,0 +1,217 @@
+package tap13
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrPlanAlreadyWritten is returned by Writer.Plan if a plan has already been written.
+var ErrPlanAlreadyWritten = errors.New("tap13: plan already written")
+
+// Writer produces well-formed TAP version 13 output. The version line is written before the first
+// line of output, and tests are numbered automatically, starting from 1. A plan may be written
+// before the first test, or after the last test (a "late plan"); if no plan is written, Done
+// writes one based on the number of tests written.
+//
+// Descriptions and directive reasons are written on a single line, with "#" and "\" escaped, so
+// that they can be distinguished from directives. The first error encountered while writing is
+// returned by every subsequent call.
+type Writer struct {
+	w           io.Writer
+	parent      *Writer
+	err         error
+	started     bool
+	planWritten bool
+	count       int
+}
+
+// NewWriter returns a Writer which writes TAP output to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Count returns the number of tests written so far.
+func (w *Writer) Count() int {
+	return w.count
+}
+
+func (w *Writer) writeLine(format string, args ...interface{}) error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.parent != nil {
+		w.err = w.parent.writeLine("%s%s", subtestIndent, fmt.Sprintf(format, args...))
+		return w.err
+	}
+	if !w.started {
+		w.started = true
+		if _, w.err = io.WriteString(w.w, "TAP version 13\n"); w.err != nil {
+			return w.err
+		}
+	}
+	_, w.err = fmt.Fprintf(w.w, format+"\n", args...)
+	return w.err
+}
+
+// Plan writes a plan declaring that n tests will be run.
+func (w *Writer) Plan(n int) error {
+	if w.planWritten {
+		return ErrPlanAlreadyWritten
+	}
+	w.planWritten = true
+	return w.writeLine("1..%d", n)
+}
+
+// SkipAll writes a plan declaring that no tests will be run, because the whole test run was
+// skipped for the specified reason. No tests should be written.
+func (w *Writer) SkipAll(reason string) error {
+	if w.planWritten {
+		return ErrPlanAlreadyWritten
+	}
+	w.planWritten = true
+	return w.writeLine("1..0 # %s", directive("SKIP", reason))
+}
+
+// Pragma writes a pragma line which enables (or disables) the pragma with the specified name.
+func (w *Writer) Pragma(name string, enabled bool) error {
+	if enabled {
+		return w.writeLine("pragma +%s", name)
+	}
+	return w.writeLine("pragma -%s", name)
+}
+
+// Ok writes a passing test with the specified description.
+func (w *Writer) Ok(description string) error {
+	return w.writeTest(true, description, "")
+}
+
+// NotOk writes a failing test with the specified description. If yamlData is not nil, it is
+// marshalled into a YAML diagnostic block following the test line.
+func (w *Writer) NotOk(description string, yamlData interface{}) error {
+	if err := w.writeTest(false, description, ""); err != nil {
+		return err
+	}
+	if yamlData == nil {
+		return nil
+	}
+	return w.YAML(yamlData)
+}
+
+// Skip writes a skipped test with the specified description and reason.
+func (w *Writer) Skip(description string, reason string) error {
+	return w.writeTest(true, description, directive("SKIP", reason))
+}
+
+// Todo writes a (failing) TODO test with the specified description and reason.
+func (w *Writer) Todo(description string, reason string) error {
+	return w.writeTest(false, description, directive("TODO", reason))
+}
+
+// Diag writes the specified message as diagnostic lines. Each line of the message is written as
+// a separate diagnostic line.
+func (w *Writer) Diag(message string) error {
+	for _, line := range strings.Split(message, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			if err := w.writeLine("#"); err != nil {
+				return err
+			}
+		} else if err := w.writeLine("# %s", line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// YAML marshals the specified value into a YAML diagnostic block, which will be associated with
+// the most recently written test.
+func (w *Writer) YAML(yamlData interface{}) error {
+	if w.err != nil {
+		return w.err
+	}
+	encoded, err := yaml.Marshal(yamlData)
+	if err != nil {
+		return err
+	}
+	if err := w.writeLine("  ---"); err != nil {
+		return err
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(string(encoded), "\n"), "\n") {
+		if err := w.writeLine("  %s", line); err != nil {
+			return err
+		}
+	}
+	return w.writeLine("  ...")
+}
+
+// BailOut writes a line indicating that the test run was aborted for the specified reason.
+func (w *Writer) BailOut(reason string) error {
+	reason = singleLine(reason)
+	if reason == "" {
+		return w.writeLine("Bail out!")
+	}
+	return w.writeLine("Bail out! %s", reason)
+}
+
+// Subtest writes a "# Subtest" line with the specified name, and returns a Writer for the subtest.
+// The subtest is written (indented) as part of the output of w. When the subtest is complete, call
+// Done on the returned Writer, and then write a test on w (such as with Ok or NotOk) to summarize
+// the result of the subtest.
+func (w *Writer) Subtest(name string) *Writer {
+	if name = singleLine(name); name == "" {
+		w.writeLine("# Subtest")
+	} else {
+		w.writeLine("# Subtest: %s", name)
+	}
+	return &Writer{parent: w, started: true, err: w.err}
+}
+
+// Done writes a late plan (based on the number of tests written) if no plan has been written.
+func (w *Writer) Done() error {
+	if w.planWritten {
+		return w.err
+	}
+	return w.Plan(w.count)
+}
+
+func (w *Writer) writeTest(ok bool, description string, directive string) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.count++
+	line := fmt.Sprintf("ok %d", w.count)
+	if !ok {
+		line = "not " + line
+	}
+	if description = escape(description); description != "" {
+		line += " " + description
+	}
+	if directive != "" {
+		line += " # " + directive
+	}
+	return w.writeLine("%s", line)
+}
+
+func directive(keyword string, reason string) string {
+	if reason = escape(reason); reason != "" {
+		return keyword + " " + reason
+	}
+	return keyword
+}
+
+// singleLine replaces any line endings in s with spaces, and trims the surrounding whitespace.
+func singleLine(s string) string {
+	s = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(s)
+	return strings.TrimSpace(s)
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, "#", `\#`)
+
+// escape prepares s to be written as a description or directive reason.
+func escape(s string) string {
+	return escaper.Replace(singleLine(s))
+}
@@ -0,0 +1,155 @@
+package tap13
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWriter(t *testing.T) {
+	t.Run("WritesWellFormedTap", func(t *testing.T) {
+		var buffer bytes.Buffer
+		w := NewWriter(&buffer)
+		assert.NoError(t, w.Plan(5))
+		assert.NoError(t, w.Ok("first"))
+		assert.NoError(t, w.NotOk("second", map[string]interface{}{
+			"message": "values differ",
+			"got":     1,
+		}))
+		assert.NoError(t, w.Diag("some\ndiagnostics"))
+		assert.NoError(t, w.Skip("third", "no database"))
+		assert.NoError(t, w.Todo("fourth", ""))
+		assert.NoError(t, w.Ok(""))
+		assert.NoError(t, w.Done())
+		assert.Equal(t, 5, w.Count())
+		assert.Equal(t, `TAP version 13
+1..5
+ok 1 first
+not ok 2 second
+  ---
+  got: 1
+  message: values differ
+  ...
+# some
+# diagnostics
+ok 3 third # SKIP no database
+not ok 4 fourth # TODO
+ok 5
+`, buffer.String())
+	})
+	t.Run("WritesLatePlan", func(t *testing.T) {
+		var buffer bytes.Buffer
+		w := NewWriter(&buffer)
+		assert.NoError(t, w.Ok("one"))
+		assert.NoError(t, w.Ok("two"))
+		assert.NoError(t, w.Done())
+		assert.Equal(t, "TAP version 13\nok 1 one\nok 2 two\n1..2\n", buffer.String())
+		assert.Equal(t, ErrPlanAlreadyWritten, w.Plan(2))
+	})
+	t.Run("WritesSkippedPlan", func(t *testing.T) {
+		var buffer bytes.Buffer
+		w := NewWriter(&buffer)
+		assert.NoError(t, w.SkipAll("no database"))
+		assert.NoError(t, w.Done())
+		assert.Equal(t, "TAP version 13\n1..0 # SKIP no database\n", buffer.String())
+		results := Parse(strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n"))
+		assert.True(t, results.PlanSkipped)
+		assert.Equal(t, "no database", results.PlanSkipReason)
+		assert.Equal(t, ErrPlanAlreadyWritten, w.SkipAll(""))
+	})
+	t.Run("EscapesDescriptionsAndReasons", func(t *testing.T) {
+		var buffer bytes.Buffer
+		w := NewWriter(&buffer)
+		assert.NoError(t, w.Ok(`hash # and \ backslash`))
+		assert.NoError(t, w.Skip("multi\nline", "issue #12"))
+		assert.NoError(t, w.BailOut("out\nof towels"))
+		assert.Equal(t, `TAP version 13
+ok 1 hash \# and \\ backslash
+ok 2 multi line # SKIP issue \#12
+Bail out! out of towels
+`, buffer.String())
+		result := Parse(strings.Split(buffer.String(), "\n"))
+		assert.Equal(t, `hash # and \ backslash`, result.Tests[0].Description)
+		assert.Equal(t, "SKIP issue #12", result.Tests[1].DirectiveText)
+	})
+	t.Run("ReturnsFirstError", func(t *testing.T) {
+		w := NewWriter(failingWriter{})
+		assert.EqualError(t, w.Ok("one"), "disk full")
+		assert.EqualError(t, w.Diag("two"), "disk full")
+		assert.EqualError(t, w.Done(), "disk full")
+	})
+	t.Run("RoundTripsThroughParse", func(t *testing.T) {
+		var buffer bytes.Buffer
+		w := NewWriter(&buffer)
+		assert.NoError(t, w.Diag("Explanation"))
+		assert.NoError(t, w.Ok("first"))
+		assert.NoError(t, w.NotOk("second", map[string]interface{}{"got": 1}))
+		assert.NoError(t, w.Diag("Failed"))
+		assert.NoError(t, w.Skip("third", "no database"))
+		assert.NoError(t, w.Todo("fourth", "later"))
+		assert.NoError(t, w.Done())
+		result := Parse(strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n"))
+		assert.Equal(t, 13, result.TapVersion)
+		assert.Equal(t, 4, result.ExpectedTests)
+		assert.Equal(t, 4, result.TotalTests)
+		assert.Equal(t, []string{"Explanation"}, result.Explanation)
+		assert.Equal(t, []Test{
+			{TestNumber: 1, Ok: true, Passed: true, Description: "first",
+				StartLine: 3, EndLine: 3, Raw: "ok 1 first"},
+			{
+				TestNumber:  2,
+				Failed:      true,
+				Description: "second",
+				Diagnostics: []string{"Failed"},
+				YamlBytes:   []byte("  got: 1\n"),
+				StartLine:   4,
+				EndLine:     8,
+				Raw:         "not ok 2 second",
+			},
+			{
+				TestNumber:    3,
+				Ok:            true,
+				Skipped:       true,
+				Description:   "third",
+				DirectiveText: "SKIP no database",
+				StartLine:     9,
+				EndLine:       9,
+				Raw:           "ok 3 third # SKIP no database",
+			},
+			{TestNumber: 4, Todo: true, Description: "fourth", DirectiveText: "TODO later",
+				StartLine: 10, EndLine: 10, Raw: "not ok 4 fourth # TODO later"},
+		}, result.Tests)
+		assert.Empty(t, result.Problems)
+	})
+	t.Run("WritesSubtests", func(t *testing.T) {
+		var buffer bytes.Buffer
+		w := NewWriter(&buffer)
+		subtest := w.Subtest("foo")
+		assert.NoError(t, subtest.Ok("first"))
+		assert.NoError(t, subtest.Diag("in subtest"))
+		assert.NoError(t, subtest.Done())
+		assert.NoError(t, w.Ok("foo"))
+		assert.NoError(t, w.Done())
+		assert.Equal(t, `TAP version 13
+# Subtest: foo
+    ok 1 first
+    # in subtest
+    1..1
+ok 1 foo
+1..1
+`, buffer.String())
+		result := Parse(strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n"))
+		assert.True(t, result.IsPassing())
+		assert.Equal(t, "foo", result.Tests[0].Subtests.Name)
+		assert.Equal(t, 1, result.Tests[0].Subtests.PassedTests)
+	})
+}
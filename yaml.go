@@ -0,0 +1,77 @@
+package tap13
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// Diagnostic contains the conventional keys found in the YAML diagnostic block following a test
+// line. Keys which are not present in the YAML block are left as zero values. If the File and Line
+// keys are not present, but the "at" key contains a mapping with "file" and "line" keys (as
+// produced by node-tap), they are populated from it.
+type Diagnostic struct {
+	Message    string      `yaml:"message"`
+	Severity   string      `yaml:"severity"`
+	Data       interface{} `yaml:"data"`
+	Got        interface{} `yaml:"got"`
+	Expected   interface{} `yaml:"expected"`
+	Wanted     interface{} `yaml:"wanted"`
+	Found      interface{} `yaml:"found"`
+	At         interface{} `yaml:"at"`
+	File       string      `yaml:"file"`
+	Line       int         `yaml:"line"`
+	DurationMS float64     `yaml:"duration_ms"`
+	Datetime   string      `yaml:"datetime"`
+}
+
+// Actual returns the actual value reported by the test, from either the "got" or "found" key.
+func (d *Diagnostic) Actual() interface{} {
+	if d.Got != nil {
+		return d.Got
+	}
+	return d.Found
+}
+
+// Expectation returns the value expected by the test, from either the "expected" or "wanted" key.
+func (d *Diagnostic) Expectation() interface{} {
+	if d.Expected != nil {
+		return d.Expected
+	}
+	return d.Wanted
+}
+
+// YAML decodes the YAML diagnostic block following the test line. If the test had no YAML block,
+// a nil map is returned.
+func (t *Test) YAML() (map[string]interface{}, error) {
+	if len(t.YamlBytes) == 0 {
+		return nil, nil
+	}
+	var result map[string]interface{}
+	err := yaml.Unmarshal(t.YamlBytes, &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Diagnostic decodes the conventional keys of the YAML diagnostic block following the test line.
+// If the test had no YAML block, nil is returned. If any of the conventional keys contains a value
+// of an unexpected type, an error is returned along with the keys that could be decoded.
+func (t *Test) Diagnostic() (*Diagnostic, error) {
+	if len(t.YamlBytes) == 0 {
+		return nil, nil
+	}
+	result := &Diagnostic{}
+	err := yaml.Unmarshal(t.YamlBytes, result)
+	if _, ok := err.(*yaml.TypeError); err != nil && !ok {
+		return nil, err
+	}
+	if at, ok := result.At.(map[string]interface{}); ok {
+		if file, ok := at["file"].(string); ok && result.File == "" {
+			result.File = file
+		}
+		if line, ok := at["line"].(int); ok && result.Line == 0 {
+			result.Line = line
+		}
+	}
+	return result, err
+}
@@ -0,0 +1,100 @@
+package tap13
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	util "github.com/mpontillo/tap13/internal"
+)
+
+func TestDecodeYaml(t *testing.T) {
+	t.Run("NoYamlReturnsNil", func(t *testing.T) {
+		test := Test{}
+		yaml, err := test.YAML()
+		assert.NoError(t, err)
+		assert.Nil(t, yaml)
+		diagnostic, err := test.Diagnostic()
+		assert.NoError(t, err)
+		assert.Nil(t, diagnostic)
+	})
+	t.Run("DecodesIndentedYaml", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+not ok 1
+  ---
+  message: "Board layout"
+  severity: fail
+  data:
+    got: 1
+  ...`,
+			"\n")
+		result := Parse(input)
+		yaml, err := result.Tests[0].YAML()
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"message":  "Board layout",
+			"severity": "fail",
+			"data":     map[string]interface{}{"got": 1},
+		}, yaml)
+	})
+	t.Run("DecodesConventionalKeys", func(t *testing.T) {
+		input := strings.Split(`TAP version 13
+not ok 1
+  ---
+  message: "Value mismatch"
+  severity: fail
+  found: 3
+  wanted: 4
+  at:
+    file: test.js
+    line: 12
+    column: 5
+  duration_ms: 1.5
+  datetime: 2020-06-01T12:00:00Z
+  ...`,
+			"\n")
+		result := Parse(input)
+		diagnostic, err := result.Tests[0].Diagnostic()
+		assert.NoError(t, err)
+		assert.Equal(t, "Value mismatch", diagnostic.Message)
+		assert.Equal(t, "fail", diagnostic.Severity)
+		assert.Equal(t, 3, diagnostic.Actual())
+		assert.Equal(t, 4, diagnostic.Expectation())
+		assert.Equal(t, "test.js", diagnostic.File)
+		assert.Equal(t, 12, diagnostic.Line)
+		assert.Equal(t, 1.5, diagnostic.DurationMS)
+		assert.Equal(t, "2020-06-01T12:00:00Z", diagnostic.Datetime)
+	})
+	t.Run("TopLevelFileAndLineTakePrecedence", func(t *testing.T) {
+		test := Test{YamlBytes: []byte("  got: a\n  expected: b\n  file: x.pl\n  line: 7\n" +
+			"  at:\n    file: y.pl\n    line: 8\n")}
+		diagnostic, err := test.Diagnostic()
+		assert.NoError(t, err)
+		assert.Equal(t, "a", diagnostic.Actual())
+		assert.Equal(t, "b", diagnostic.Expectation())
+		assert.Equal(t, "x.pl", diagnostic.File)
+		assert.Equal(t, 7, diagnostic.Line)
+	})
+	t.Run("ReturnsPartialDiagnosticOnTypeMismatch", func(t *testing.T) {
+		test := Test{YamlBytes: []byte("  message: hello\n  line: [1, 2]\n")}
+		diagnostic, err := test.Diagnostic()
+		assert.Error(t, err)
+		assert.Equal(t, "hello", diagnostic.Message)
+	})
+	t.Run("ReturnsErrorForInvalidYaml", func(t *testing.T) {
+		test := Test{YamlBytes: []byte("  foo: [\n")}
+		_, err := test.YAML()
+		assert.Error(t, err)
+		diagnostic, err := test.Diagnostic()
+		assert.Error(t, err)
+		assert.Nil(t, diagnostic)
+	})
+	t.Run("DecodesTestData", func(t *testing.T) {
+		result := Parse(util.ReadFile("testdata/creative_liberties.tap13"))
+		diagnostic, err := result.Tests[7].Diagnostic()
+		assert.NoError(t, err)
+		assert.Equal(t, "Board layout", diagnostic.Message)
+		assert.Equal(t, "comment", diagnostic.Severity)
+	})
+}